@@ -0,0 +1,124 @@
+package brest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+)
+
+// AggregateFunc aggregate function type
+type AggregateFunc string
+
+const (
+	// Count aggregate function (count(*) or count(attribute))
+	Count AggregateFunc = "count"
+	// Sum aggregate function
+	Sum AggregateFunc = "sum"
+	// Avg aggregate function
+	Avg AggregateFunc = "avg"
+	// Min aggregate function
+	Min AggregateFunc = "min"
+	// Max aggregate function
+	Max AggregateFunc = "max"
+)
+
+// Aggregate structure
+type Aggregate struct {
+	Func AggregateFunc
+	Attr string
+}
+
+func (a *Aggregate) String() string {
+	return fmt.Sprintf("%v(%v)", a.Func, a.Attr)
+}
+
+// AggregatePage structure, each row maps group fields and aggregates (e.g. "avg(NbPages)") to values
+type AggregatePage struct {
+	Rows   []map[string]interface{} `json:"rows"`
+	Offset int                      `json:"offset"`
+	Limit  int                      `json:"limit"`
+	Count  int                      `json:"count"`
+}
+
+// NewAggregatePage constructs AggregatePage
+func NewAggregatePage(rows []map[string]interface{}, count int, restQuery *RestQuery) *AggregatePage {
+	p := new(AggregatePage)
+	p.Rows = rows
+	p.Offset = restQuery.Offset
+	p.Limit = restQuery.Limit
+	p.Count = count
+	return p
+}
+
+// AggregateExecFunc aggregates execution function, entity is a pointer to a slice of maps
+func (e *Executor) AggregateExecFunc() ExecFunc {
+	return func(ctx context.Context, tx *bun.Tx) error {
+		table := tx.Dialect().Tables().Get(e.resource.ResourceType())
+		model := reflect.New(e.resource.ResourceType()).Interface()
+		q := tx.NewSelect().Model(model)
+		for _, group := range e.restQuery.GroupBy {
+			field := tableField(table, group.Name)
+			if field == nil {
+				return NewErrorBadRequest(fmt.Sprintf("unknown group by field '%v' for resource '%v'", group.Name, e.resource.Name()))
+			}
+			q = q.ColumnExpr("?TableAlias.? AS ?", bun.Ident(field.Name), bun.Ident(field.GoName))
+			q = q.GroupExpr("?TableAlias.?", bun.Ident(field.Name))
+			q = q.OrderExpr("?TableAlias.?", bun.Ident(field.Name))
+		}
+		for _, aggregate := range e.restQuery.Aggregates {
+			switch aggregate.Func {
+			case Count, Sum, Avg, Min, Max:
+			default:
+				return NewErrorBadRequest(fmt.Sprintf("unknown aggregate function '%v'", aggregate.Func))
+			}
+			if aggregate.Attr == "*" && aggregate.Func == Count {
+				q = q.ColumnExpr("count(*) AS ?", bun.Ident(aggregate.String()))
+				continue
+			}
+			field := tableField(table, aggregate.Attr)
+			if field == nil {
+				return NewErrorBadRequest(fmt.Sprintf("unknown aggregate field '%v' for resource '%v'", aggregate.Attr, e.resource.Name()))
+			}
+			alias := fmt.Sprintf("%v(%v)", aggregate.Func, field.GoName)
+			q = q.ColumnExpr(string(aggregate.Func)+"(?TableAlias.?) AS ?", bun.Ident(field.Name), bun.Ident(alias))
+		}
+		q, err := e.addQueryConditions(q)
+		if err != nil {
+			return NewErrorFromCause(err)
+		}
+		if err = e.runSelectQueryHooks(ctx, q); err != nil {
+			return NewErrorFromCause(err)
+		}
+		if len(e.restQuery.GroupBy) == 0 {
+			e.count = 1
+		} else if e.count, err = tx.NewSelect().TableExpr("(?) AS aggregate", q).Count(ctx); err != nil {
+			return NewErrorFromCause(err)
+		}
+		q = addQueryLimit(q, e.restQuery.Limit)
+		q = addQueryOffset(q, e.restQuery.Offset)
+		if err = q.Scan(ctx, e.entity); err != nil {
+			return NewErrorFromCause(err)
+		}
+		return nil
+	}
+}
+
+// executeAggregate executes aggregation rest query, BeforeList hooks run before query with empty entity,
+// AfterList hooks don't run as rows aren't entities
+func (e *Engine) executeAggregate(ctx context.Context, restQuery *RestQuery, resource *Resource) (interface{}, int, error) {
+	entity := reflect.New(resource.ResourceType()).Interface()
+	if err := e.runBeforeHooks(ctx, BeforeList, restQuery, resource, entity); err != nil {
+		if sc, ok := shortCircuitFromError(err); ok {
+			return sc.Result, statusCode(Get, false), nil
+		}
+		return nil, 0, NewErrorFromCause(err)
+	}
+	rows := make([]map[string]interface{}, 0)
+	executor := NewExecutor(e.config, restQuery, &rows)
+	if err := executor.Execute(ctx, executor.AggregateExecFunc()); err != nil {
+		return nil, 0, NewErrorFromCause(err)
+	}
+	return NewAggregatePage(rows, executor.Count(), restQuery), statusCode(Get, false), nil
+}
@@ -0,0 +1,154 @@
+package brest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestAggregate(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	for i, book := range books {
+		book.NbPages = 100 * (i%3 + 1)
+		_, err = db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 10, Aggregates: []*brest.Aggregate{{Func: brest.Count, Attr: "*"}, {Func: brest.Max, Attr: "nb_pages"}}, GroupBy: []*brest.Field{{Name: "AuthorID"}}})
+	assert.Nil(t, err)
+	page := res.(*brest.AggregatePage)
+	assert.Equal(t, 3, page.Count)
+	assert.Equal(t, 3, len(page.Rows))
+	assert.EqualValues(t, 1, page.Rows[0]["AuthorID"])
+	assert.EqualValues(t, 6, page.Rows[0]["count(*)"])
+	assert.EqualValues(t, 300, page.Rows[0]["max(NbPages)"])
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Aggregates: []*brest.Aggregate{{Func: brest.Sum, Attr: "NbPages"}}, Filter: &brest.Filter{Op: brest.Eq, Attr: "author_id", Value: 2}})
+	assert.Nil(t, err)
+	page = res.(*brest.AggregatePage)
+	assert.Equal(t, 1, len(page.Rows))
+	assert.EqualValues(t, 900, page.Rows[0]["sum(NbPages)"])
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Aggregates: []*brest.Aggregate{{Func: brest.Avg, Attr: "nb_pages); DROP TABLE books; --"}}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Aggregates: []*brest.Aggregate{{Func: "median", Attr: "NbPages"}}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+}
+
+func TestAggregateListHooks(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	for _, book := range books {
+		_, err := db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	// list hooks restrict aggregation as they restrict listing
+	rows := 0
+	config.GetResource("Book").AddHook(brest.BeforeList, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		restQuery.Filter = &brest.Filter{Op: brest.Eq, Attr: "author_id", Value: 3}
+		return nil
+	})
+	config.GetResource("Book").AddHook(brest.AfterList, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		rows++
+		return nil
+	})
+
+	res, err := engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Aggregates: []*brest.Aggregate{{Func: brest.Count, Attr: "*"}}})
+	assert.Nil(t, err)
+	page := res.(*brest.AggregatePage)
+	assert.EqualValues(t, 1, page.Rows[0]["count(*)"])
+	// rows aren't entities, AfterList hooks don't run
+	assert.Equal(t, 0, rows)
+
+	// hooks of every event receive entities of resource
+	for _, author := range authors {
+		_, err = db.NewInsert().Model(&author).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Author", Aggregates: []*brest.Aggregate{{Func: brest.Count, Attr: "*"}}})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, res.(*brest.AggregatePage).Rows[0]["count(*)"])
+
+	config.AddHook(brest.BeforeList, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		return brest.NewErrorForbbiden("forbidden")
+	})
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Aggregates: []*brest.Aggregate{{Func: brest.Count, Attr: "*"}}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 403, err.(*brest.Error).Code)
+}
+
+func TestServerAggregate(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var err error
+	var req *http.Request
+	var body []byte
+	var res *http.Response
+
+	for _, book := range books {
+		_, err = db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/Book?aggregate=count(*)&groupBy=AuthorID", bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	jsonPage := &struct {
+		Rows  []map[string]int `json:"rows"`
+		Count int              `json:"count"`
+	}{}
+	err = json.Unmarshal(body, jsonPage)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, jsonPage.Count)
+	assert.Equal(t, 5, jsonPage.Rows[1]["count(*)"])
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/Book?aggregate=count(*)&groupBy=AuthorID", bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	req.Header.Set("Accept", brest.Msgpack)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	msgpackPage := &struct {
+		Rows  []map[string]int `msgpack:"rows"`
+		Count int              `msgpack:"count"`
+	}{}
+	err = msgpack.Unmarshal(body, msgpackPage)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, msgpackPage.Count)
+	assert.Equal(t, 1, msgpackPage.Rows[2]["count(*)"])
+}
@@ -0,0 +1,83 @@
+package brest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// alternateKey returns alternate key field name and value of key, empty name if key designates pk
+//
+// Key is "field:value" for declared alternate key field, key value of lookup field otherwise.
+// Matrix parameter keys (";id=5") always designate pk.
+func (r *Resource) alternateKey(key string) (string, string) {
+	if strings.HasPrefix(key, ";") {
+		return "", key
+	}
+	if i := strings.Index(key, ":"); i > 0 {
+		for _, name := range r.alternateKeys {
+			if strings.EqualFold(name, key[:i]) {
+				return name, key[i+1:]
+			}
+		}
+	}
+	return r.lookupField, key
+}
+
+// pkKey returns key of elem designating pk, matrix parameters are used when resource has lookup field
+func (r *Resource) pkKey(db *bun.DB, elem reflect.Value) (string, error) {
+	key, err := getPk(db, r.ResourceType(), elem)
+	if err != nil || r.lookupField == "" {
+		return key, err
+	}
+	table := db.Table(r.ResourceType())
+	values := strings.Split(key, ",")
+	for i, pk := range table.PKs {
+		values[i] = ";" + pk.Name + "=" + values[i]
+	}
+	return strings.Join(values, ""), nil
+}
+
+// setKey sets pk of elem from key, alternate key is resolved to pk
+func (e *Engine) setKey(ctx context.Context, resource *Resource, elem reflect.Value, key string) error {
+	name, value := resource.alternateKey(key)
+	if name == "" {
+		return setPk(e.config.DB(), resource.ResourceType(), elem, key)
+	}
+	table := e.config.DB().Table(resource.ResourceType())
+	field := tableField(table, name)
+	if field == nil {
+		return NewErrorBadRequest(fmt.Sprintf("unknown alternate key '%v' for resource '%v'", name, resource.Name()))
+	}
+	unescaped, err := url.PathUnescape(value)
+	if err != nil {
+		return NewErrorBadRequest(fmt.Sprintf("invalid key '%v' for resource '%v'", key, resource.Name()))
+	}
+	found := reflect.New(resource.ResourceType()).Elem()
+	if err = field.ScanValue(found, unescaped); err != nil {
+		return NewErrorBadRequest(fmt.Sprintf("invalid key '%v' for resource '%v'", key, resource.Name()))
+	}
+	err = Execute(ctx, func(ctx context.Context, tx *bun.Tx) error {
+		q := tx.NewSelect().Model(found.Addr().Interface()).Where("?TableAlias.? = ?", bun.Ident(field.Name), field.Value(found).Interface())
+		for _, pk := range table.PKs {
+			q = q.Column(pk.Name)
+		}
+		return q.Limit(1).Scan(ctx)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return NewErrorNotFound(fmt.Sprintf("resource '%v' with key '%v' not found", resource.Name(), key))
+	}
+	if err != nil {
+		return NewErrorFromCause(err)
+	}
+	for _, pk := range table.PKs {
+		pk.Value(elem).Set(pk.Value(found))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package brest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+type Country struct {
+	ID   int    `bun:",pk,autoincrement"`
+	Code string `bun:",unique"`
+	Slug string `bun:",unique"`
+	Name string
+}
+
+func TestAlternateKey(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	country := brest.NewResource("Country", (*Country)(nil), brest.All)
+	country.AddAlternateKey("slug")
+	country.SetLookupField("Code")
+	config.AddResource(country)
+	db.ResetModel(context.Background(), (*Country)(nil))
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Country", ContentType: brest.Json, Content: []byte(`{"Code":"FR","Slug":"france","Name":"France"}`)})
+	assert.Nil(t, err)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Country", ContentType: brest.Json, Content: []byte(`{"Code":"DE","Slug":"germany","Name":"Germany"}`)})
+	assert.Nil(t, err)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Country", Key: "DE"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.(*Country).ID)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Country", Key: "slug:france"})
+	assert.Nil(t, err)
+	assert.Equal(t, "FR", res.(*Country).Code)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Country", Key: ";id=2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "DE", res.(*Country).Code)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Country", Key: "slug:germany", ContentType: brest.Json, Content: []byte(`{"Slug":"deutschland"}`)})
+	assert.Nil(t, err)
+	assert.Equal(t, "Germany", res.(*Country).Name)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Put, Resource: "Country", Key: "FR", ContentType: brest.Json, Content: []byte(`{"Code":"FR","Slug":"france","Name":"République française"}`)})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.(*Country).ID)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Country", Key: "slug:deutschland"})
+	assert.Nil(t, err)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Country", Key: "DE"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+
+	countries := make([]Country, 0)
+	err = db.NewSelect().Model(&countries).Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(countries))
+	assert.Equal(t, "République française", countries[0].Name)
+}
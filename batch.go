@@ -0,0 +1,184 @@
+package brest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// BatchResource name of batch endpoint resource ({prefix}$batch)
+const BatchResource = "$batch"
+
+// BatchOperation structure
+//
+// String values of key and body like "$0.ID" or "$author.ID" reference a field of
+// a previous operation result by index or id, "$$" escapes a leading "$".
+type BatchOperation struct {
+	ID       string          `json:"id,omitempty"`
+	Method   string          `json:"method"`
+	Resource string          `json:"resource"`
+	Key      string          `json:"key,omitempty"`
+	Body     json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult structure
+type BatchResult struct {
+	ID     string      `json:"id,omitempty"`
+	Status int         `json:"status"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+var batchReference = regexp.MustCompile(`^\$([A-Za-z0-9_-]+)((?:\.[^.]+)*)$`)
+
+// batchOperations decodes batch operations from content
+func batchOperations(restQuery *RestQuery) ([]*BatchOperation, error) {
+	switch content := restQuery.Content.(type) {
+	case []*BatchOperation:
+		return content, nil
+	case []byte:
+		if !regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.ContentType) {
+			return nil, NewErrorBadRequest(fmt.Sprintf("content type '%v' not supported for batch", restQuery.ContentType))
+		}
+		operations := make([]*BatchOperation, 0)
+		if err := json.Unmarshal(content, &operations); err != nil {
+			return nil, NewErrorBadRequest(fmt.Sprintf("invalid batch operations: %v", err))
+		}
+		return operations, nil
+	}
+	return nil, NewErrorBadRequest("invalid batch operations")
+}
+
+// executeBatch executes batch operations in order in one transaction
+func (e *Engine) executeBatch(ctx context.Context, restQuery *RestQuery) (interface{}, int, error) {
+	if restQuery.Action != Post {
+		return nil, 0, NewErrorBadRequest(fmt.Sprintf("action '%v' not supported for batch", restQuery.Action))
+	}
+	operations, err := batchOperations(restQuery)
+	if err != nil {
+		return nil, 0, err
+	}
+	results := make([]*BatchResult, 0, len(operations))
+	err = Execute(ctx, func(ctx context.Context, tx *bun.Tx) error {
+		references := make(map[string]interface{})
+		for i, operation := range operations {
+			opQuery, err := newBatchQuery(restQuery, operation, references)
+			if err != nil {
+				return &Error{Message: fmt.Sprintf("batch operation %v failed", i), Cause: err, Code: NewErrorFromCause(err).StatusCode()}
+			}
+			res, status, err := e.executeContext(ctx, opQuery)
+			if err != nil {
+				cerr := NewErrorFromCause(err)
+				return &Error{Message: fmt.Sprintf("batch operation %v failed", i), Cause: cerr, Code: cerr.StatusCode()}
+			}
+			if res == nil {
+				return NewErrorNotFound(fmt.Sprintf("batch operation %v failed: resource not found", i))
+			}
+			results = append(results, &BatchResult{ID: operation.ID, Status: status, Body: res})
+			data, err := json.Marshal(res)
+			if err != nil {
+				return err
+			}
+			var doc interface{}
+			if err = decodeJSON(data, &doc); err != nil {
+				return err
+			}
+			references[strconv.Itoa(i)] = doc
+			if operation.ID != "" {
+				references[operation.ID] = doc
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, NewErrorFromCause(err)
+	}
+	return results, http.StatusOK, nil
+}
+
+// newBatchQuery constructs rest query of batch operation resolving references
+func newBatchQuery(restQuery *RestQuery, operation *BatchOperation, references map[string]interface{}) (*RestQuery, error) {
+	action := actionFromMethod(operation.Method)
+	if action == None {
+		return nil, NewErrorBadRequest(fmt.Sprintf("unknown method '%v'", operation.Method))
+	}
+	if operation.Resource == BatchResource {
+		return nil, NewErrorBadRequest("nested batch is forbidden")
+	}
+	key, err := resolveReferences(operation.Key, references)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, NewErrorBadRequest(fmt.Sprintf("batch reference '%v' of key is unresolved", operation.Key))
+	}
+	opQuery := &RestQuery{
+		Request:     restQuery.Request,
+		Action:      action,
+		Resource:    operation.Resource,
+		Key:         fmt.Sprint(key),
+		ContentType: Json,
+		Accept:      restQuery.Accept,
+		Debug:       restQuery.Debug,
+	}
+	if len(operation.Body) > 0 {
+		var body interface{}
+		if err = decodeJSON(operation.Body, &body); err != nil {
+			return nil, NewErrorBadRequest(fmt.Sprintf("invalid body: %v", err))
+		}
+		if body, err = resolveReferences(body, references); err != nil {
+			return nil, err
+		}
+		if opQuery.Content, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	return opQuery, nil
+}
+
+// resolveReferences replaces references to previous results in decoded JSON value
+func resolveReferences(value interface{}, references map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, "$$") {
+			return v[1:], nil
+		}
+		res := batchReference.FindStringSubmatch(v)
+		if res == nil {
+			return v, nil
+		}
+		node, ok := references[res[1]]
+		if !ok {
+			return nil, NewErrorBadRequest(fmt.Sprintf("unknown batch reference '%v'", v))
+		}
+		if res[2] != "" {
+			var err error
+			if node, err = pointerGet(node, strings.Split(res[2][1:], ".")); err != nil {
+				return nil, NewErrorBadRequest(fmt.Sprintf("unknown batch reference '%v'", v))
+			}
+		}
+		return node, nil
+	case map[string]interface{}:
+		for key, item := range v {
+			resolved, err := resolveReferences(item, references)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := resolveReferences(item, references)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	}
+	return value, nil
+}
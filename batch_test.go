@@ -0,0 +1,90 @@
+package brest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var err error
+	var req *http.Request
+	var body []byte
+	var res *http.Response
+	var count int
+
+	req, err = http.NewRequest("POST", ts.URL+"/rest/$batch", bytes.NewBufferString(`[
+		{"id":"author","method":"POST","resource":"Author","body":{"Firstname":"Albert","Lastname":"Camus"}},
+		{"method":"POST","resource":"Book","body":{"Title":"L'Étranger","AuthorID":"$author.ID"}},
+		{"method":"POST","resource":"Book","body":{"Title":"La Peste","AuthorID":"$0.ID"}},
+		{"method":"PATCH","resource":"Book","key":"$2.ID","body":{"NbPages":336}},
+		{"method":"GET","resource":"Author","key":"$author.ID"}
+	]`))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	results := make([]*struct {
+		ID     string
+		Status int
+		Body   json.RawMessage
+	}, 0)
+	err = json.Unmarshal(body, &results)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(results))
+	assert.Equal(t, "author", results[0].ID)
+	assert.Equal(t, http.StatusCreated, results[0].Status)
+	assert.Equal(t, http.StatusOK, results[3].Status)
+	resBook := &Book{}
+	err = json.Unmarshal(results[3].Body, resBook)
+	assert.Nil(t, err)
+	assert.Equal(t, "La Peste", resBook.Title)
+	assert.Equal(t, 336, resBook.NbPages)
+	assert.Equal(t, 1, resBook.AuthorID)
+
+	// atomicity
+	req, err = http.NewRequest("POST", ts.URL+"/rest/$batch", bytes.NewBufferString(`[
+		{"method":"POST","resource":"Author","body":{"Firstname":"Jules","Lastname":"Verne"}},
+		{"method":"DELETE","resource":"Book","key":"999"}
+	]`))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	count, err = db.NewSelect().Model((*Author)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = brest.NewEngine(config).Execute(&brest.RestQuery{Action: brest.Post, Resource: brest.BatchResource, ContentType: brest.Json, Content: []byte(`[{"method":"POST","resource":"Book","body":{"AuthorID":"$1.ID"}}]`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).StatusCode())
+
+	// reference resolved to null can't be a key
+	_, err = brest.NewEngine(config).Execute(&brest.RestQuery{Action: brest.Post, Resource: brest.BatchResource, ContentType: brest.Json, Content: []byte(`[
+		{"method":"POST","resource":"Author","body":{"Firstname":"Jules"}},
+		{"method":"GET","resource":"Author","key":"$0.Picture"}
+	]`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).StatusCode())
+	assert.Contains(t, err.Error(), "batch reference '$0.Picture' of key is unresolved")
+}
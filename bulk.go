@@ -0,0 +1,295 @@
+package brest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// BulkItem structure
+type BulkItem struct {
+	Status int         `json:"status"`
+	Entity interface{} `json:"entity,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// BulkResult structure
+type BulkResult struct {
+	Items     []*BulkItem `json:"items"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+}
+
+// NewBulkResult constructs BulkResult
+func NewBulkResult() *BulkResult {
+	r := new(BulkResult)
+	r.Items = make([]*BulkItem, 0)
+	return r
+}
+
+// AddEntity adds succeeded item
+func (r *BulkResult) AddEntity(status int, entity interface{}) {
+	r.Items = append(r.Items, &BulkItem{Status: status, Entity: entity})
+	r.Succeeded++
+}
+
+// AddError adds failed item
+func (r *BulkResult) AddError(err *Error) {
+	r.Items = append(r.Items, &BulkItem{Status: err.StatusCode(), Error: err.Error()})
+	r.Failed++
+}
+
+// isBulk returns true if rest query targets collection with bulk operation
+func isBulk(restQuery *RestQuery) bool {
+	if restQuery.Key != "" {
+		return false
+	}
+	switch restQuery.Action {
+	case Patch, Delete:
+		return true
+	case Post:
+		return isCollectionContent(restQuery)
+	}
+	return false
+}
+
+// isCollectionContent returns true if content is an array
+func isCollectionContent(restQuery *RestQuery) bool {
+	switch content := restQuery.Content.(type) {
+	case nil:
+		return false
+	case []byte:
+		if regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.ContentType) {
+			trimmed := bytes.TrimSpace(content)
+			return len(trimmed) > 0 && trimmed[0] == '['
+		}
+		if regexp.MustCompile("[+-/](msgpack|messagepack)($|[+-])").MatchString(restQuery.ContentType) {
+			return len(content) > 0 && (content[0]&0xf0 == 0x90 || content[0] == 0xdc || content[0] == 0xdd)
+		}
+		return false
+	default:
+		return reflect.Indirect(reflect.ValueOf(content)).Kind() == reflect.Slice
+	}
+}
+
+// bulkContents splits collection content into items content
+func bulkContents(restQuery *RestQuery) ([]interface{}, error) {
+	contents := make([]interface{}, 0)
+	switch content := restQuery.Content.(type) {
+	case nil:
+		return nil, NewErrorBadRequest(fmt.Sprintf("action '%v': array content is mandatory without key", restQuery.Action))
+	case []byte:
+		if regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.ContentType) {
+			raws := make([]json.RawMessage, 0)
+			if err := json.Unmarshal(content, &raws); err != nil {
+				return nil, NewErrorBadRequest(fmt.Sprintf("action '%v': array content is mandatory without key", restQuery.Action))
+			}
+			for _, raw := range raws {
+				contents = append(contents, []byte(raw))
+			}
+		} else if regexp.MustCompile("[+-/](msgpack|messagepack)($|[+-])").MatchString(restQuery.ContentType) {
+			raws := make([]msgpack.RawMessage, 0)
+			if err := msgpack.Unmarshal(content, &raws); err != nil {
+				return nil, NewErrorBadRequest(fmt.Sprintf("action '%v': array content is mandatory without key", restQuery.Action))
+			}
+			for _, raw := range raws {
+				contents = append(contents, []byte(raw))
+			}
+		} else {
+			return nil, NewErrorBadRequest(fmt.Sprintf("content type '%v' not supported for bulk operations", restQuery.ContentType))
+		}
+	default:
+		v := reflect.Indirect(reflect.ValueOf(content))
+		if v.Kind() != reflect.Slice {
+			return nil, NewErrorBadRequest(fmt.Sprintf("action '%v': array content is mandatory without key", restQuery.Action))
+		}
+		for i := 0; i < v.Len(); i++ {
+			contents = append(contents, v.Index(i).Interface())
+		}
+	}
+	return contents, nil
+}
+
+// newItemQuery constructs rest query of bulk item, sub-resource scope is kept
+func newItemQuery(restQuery *RestQuery, key string, content interface{}) *RestQuery {
+	return &RestQuery{
+		Request:     restQuery.Request,
+		Action:      restQuery.Action,
+		Resource:    restQuery.Resource,
+		Key:         key,
+		ContentType: restQuery.ContentType,
+		Accept:      restQuery.Accept,
+		Content:     content,
+		Fields:      restQuery.Fields,
+		Relations:   restQuery.Relations,
+		Debug:       restQuery.Debug,
+		scope:       restQuery.scope,
+	}
+}
+
+// itemQueries builds rest queries of bulk items, key of patch items is read from content
+func (e *Engine) itemQueries(restQuery *RestQuery, resource *Resource) ([]*RestQuery, error) {
+	contents, err := bulkContents(restQuery)
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]*RestQuery, 0, len(contents))
+	for _, content := range contents {
+		var key string
+		if restQuery.Action == Patch {
+			elem := reflect.New(resource.ResourceType()).Elem()
+			if err = e.Deserialize(newItemQuery(restQuery, "", content), resource, elem.Addr().Interface()); err == nil {
+				key, err = resource.pkKey(e.config.db, elem)
+			}
+			if err != nil {
+				key = ""
+			}
+		}
+		queries = append(queries, newItemQuery(restQuery, key, content))
+	}
+	return queries, nil
+}
+
+// executeBulk executes bulk rest query in one transaction
+func (e *Engine) executeBulk(ctx context.Context, restQuery *RestQuery, resource *Resource) (interface{}, int, error) {
+	if restQuery.Action == Delete {
+		return e.executeBulkDelete(ctx, restQuery, resource)
+	}
+	result := NewBulkResult()
+	err := Execute(ctx, func(ctx context.Context, tx *bun.Tx) error {
+		queries, err := e.itemQueries(restQuery, resource)
+		if err != nil {
+			return err
+		}
+		for i, itemQuery := range queries {
+			var res interface{}
+			var status int
+			var itemErr error
+			if itemQuery.Action == Patch && itemQuery.Key == "" {
+				itemErr = NewErrorBadRequest(fmt.Sprintf("bulk item %v: key is mandatory", i))
+			} else if itemQuery.Action == Patch && itemQuery.scope != nil {
+				itemErr = e.checkInScope(ctx, tx, resource, itemQuery)
+			}
+			if itemErr == nil && restQuery.BestEffort {
+				ExecuteWithPropagation(ctx, Savepoint, func(ctx context.Context, tx *bun.Tx) error {
+					res, status, itemErr = e.executeContext(ctx, itemQuery)
+					return itemErr
+				})
+			} else if itemErr == nil {
+				res, status, itemErr = e.executeContext(ctx, itemQuery)
+			}
+			if itemErr != nil {
+				cerr := NewErrorFromCause(itemErr)
+				if !restQuery.BestEffort {
+					return &Error{Message: fmt.Sprintf("bulk item %v failed", i), Cause: cerr, Code: cerr.StatusCode()}
+				}
+				result.AddError(cerr)
+			} else {
+				result.AddEntity(status, res)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, NewErrorFromCause(err)
+	}
+	if result.Failed > 0 {
+		return result, http.StatusMultiStatus, nil
+	}
+	if restQuery.Action == Post {
+		return result, http.StatusCreated, nil
+	}
+	return result, http.StatusOK, nil
+}
+
+// executeBulkDelete deletes entities listed by filter, bounding box and search in one transaction,
+// delete hooks run for each entity and entities are deleted with one statement,
+// or each one by delete execution function of resource if set
+func (e *Engine) executeBulkDelete(ctx context.Context, restQuery *RestQuery, resource *Resource) (interface{}, int, error) {
+	if (restQuery.Filter == nil || restQuery.Filter.Op == "") && len(restQuery.Bbox) == 0 && strings.TrimSpace(restQuery.Search) == "" {
+		return nil, 0, NewErrorBadRequest("action 'Delete': key or filter is mandatory")
+	}
+	result := NewBulkResult()
+	err := Execute(ctx, func(ctx context.Context, tx *bun.Tx) error {
+		slice := reflect.New(reflect.SliceOf(resource.ResourceType()))
+		selectQuery := &RestQuery{Request: restQuery.Request, Action: Get, Resource: restQuery.Resource, Filter: restQuery.Filter, Bbox: restQuery.Bbox, Search: restQuery.Search, scope: restQuery.scope}
+		executor := NewExecutor(e.config, selectQuery, slice.Interface())
+		if err := executor.Execute(ctx, executor.GetSliceExecFunc()); err != nil {
+			return err
+		}
+		// entities short circuited by before hooks aren't deleted
+		deleted := reflect.New(slice.Elem().Type())
+		queries := make([]*RestQuery, 0, slice.Elem().Len())
+		for i := 0; i < slice.Elem().Len(); i++ {
+			elem := slice.Elem().Index(i)
+			key, err := resource.pkKey(e.config.db, elem)
+			if err != nil {
+				return err
+			}
+			itemQuery := newItemQuery(restQuery, key, nil)
+			if err = e.runBeforeHooks(ctx, BeforeDelete, itemQuery, resource, elem.Addr().Interface()); err != nil {
+				if sc, ok := shortCircuitFromError(err); ok {
+					result.AddEntity(statusCode(Delete, false), sc.Result)
+					continue
+				}
+				return err
+			}
+			deleted.Elem().Set(reflect.Append(deleted.Elem(), elem))
+			queries = append(queries, itemQuery)
+		}
+		if len(queries) == 0 {
+			return nil
+		}
+		if _, ok := resource.execFuncFactories[Delete]; ok {
+			for i, itemQuery := range queries {
+				executor = NewExecutor(e.config, itemQuery, deleted.Elem().Index(i).Addr().Interface())
+				if err := executor.Execute(ctx, resource.execFunc(Delete, executor, executor.DeleteExecFunc())); err != nil {
+					return err
+				}
+			}
+		} else {
+			executor = NewExecutor(e.config, restQuery, deleted.Interface())
+			if err := executor.Execute(ctx, executor.deleteSliceExecFunc()); err != nil {
+				return err
+			}
+		}
+		for i, itemQuery := range queries {
+			entity := deleted.Elem().Index(i).Addr().Interface()
+			if err := e.runAfterHooks(ctx, AfterDelete, itemQuery, resource, entity); err != nil {
+				return err
+			}
+			result.AddEntity(statusCode(Delete, false), entity)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, NewErrorFromCause(err)
+	}
+	return result, http.StatusOK, nil
+}
+
+// deleteSliceExecFunc deletes entities of slice by pk with one statement execution function
+func (e *Executor) deleteSliceExecFunc() ExecFunc {
+	return func(ctx context.Context, tx *bun.Tx) error {
+		q := tx.NewDelete().Model(e.entity).WherePK()
+		if err := e.runDeleteQueryHooks(ctx, q); err != nil {
+			return NewErrorFromCause(err)
+		}
+		res, err := q.Exec(ctx)
+		if err != nil {
+			return NewErrorFromCause(err)
+		}
+		if affected, err := res.RowsAffected(); err == nil {
+			e.count = int(affected)
+		}
+		return nil
+	}
+}
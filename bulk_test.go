@@ -0,0 +1,114 @@
+package brest_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+func TestBulk(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var content []byte
+	var res interface{}
+	var result *brest.BulkResult
+	var count int
+	hookCount := 0
+
+	config.GetResource("Book").AddHook(brest.BeforeCreate, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		hookCount++
+		return nil
+	})
+
+	content, err = json.Marshal(books)
+	assert.Nil(t, err)
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Book", ContentType: brest.Json, Content: content})
+	assert.Nil(t, err)
+	result = res.(*brest.BulkResult)
+	assert.Equal(t, len(books), result.Succeeded)
+	assert.Equal(t, len(books), len(result.Items))
+	assert.Equal(t, 201, result.Items[0].Status)
+	assert.Equal(t, "Courrier sud", result.Items[0].Entity.(*Book).Title)
+	assert.Equal(t, len(books), hookCount)
+
+	// all or nothing
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", ContentType: brest.Json, Content: []byte("[{\"ID\":1,\"NbPages\":10},{\"NbPages\":20}]")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).StatusCode())
+	assert.Contains(t, err.Error(), "bulk item 1: key is mandatory")
+	count, err = db.NewSelect().Model((*Book)(nil)).Where("nb_pages > 0").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	// best effort
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", ContentType: brest.Json, BestEffort: true, Content: []byte("[{\"ID\":1,\"NbPages\":10},{\"NbPages\":20},{\"ID\":999,\"NbPages\":30},{\"ID\":2,\"NbPages\":40}]")})
+	assert.Nil(t, err)
+	result = res.(*brest.BulkResult)
+	assert.Equal(t, 2, result.Succeeded)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, 200, result.Items[0].Status)
+	assert.Equal(t, 400, result.Items[1].Status)
+	assert.Equal(t, 404, result.Items[2].Status)
+	assert.Equal(t, 40, result.Items[3].Entity.(*Book).NbPages)
+	count, err = db.NewSelect().Model((*Book)(nil)).Where("nb_pages > 0").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	// filtered delete with one statement, hooks run for each entity
+	deleteHookCount := 0
+	deleteQueryCount := 0
+	config.GetResource("Book").AddHook(brest.BeforeDelete, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		deleteHookCount++
+		return nil
+	})
+	config.GetResource("Book").AddDeleteQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.DeleteQuery) error {
+		deleteQueryCount++
+		return nil
+	})
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Book"})
+	assert.NotNil(t, err)
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Book", Filter: &brest.Filter{Op: brest.Eq, Attr: "author_id", Value: 2}})
+	assert.Nil(t, err)
+	result = res.(*brest.BulkResult)
+	assert.Equal(t, 5, result.Succeeded)
+	assert.Equal(t, 5, deleteHookCount)
+	assert.Equal(t, 1, deleteQueryCount)
+	count, err = db.NewSelect().Model((*Book)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, len(books)-5, count)
+}
+
+func TestBulkDeleteExecFunc(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	for _, book := range books {
+		_, err := db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	// soft delete
+	config.GetResource("Book").SetExecFuncFactory(brest.Delete, func(executor *brest.Executor) brest.ExecFunc {
+		return func(ctx context.Context, tx *bun.Tx) error {
+			_, err := tx.NewUpdate().Model(executor.Entity()).Set("nb_pages = -1").WherePK().Exec(ctx)
+			return err
+		}
+	})
+	res, err := engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Book", Filter: &brest.Filter{Op: brest.Eq, Attr: "author_id", Value: 2}})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, res.(*brest.BulkResult).Succeeded)
+	count, err := db.NewSelect().Model((*Book)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, len(books), count)
+	count, err = db.NewSelect().Model((*Book)(nil)).Where("nb_pages = -1").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+}
@@ -0,0 +1,145 @@
+package brest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+type BookTag struct {
+	BookID int    `bun:",pk"`
+	Tag    string `bun:",pk"`
+	Weight int
+}
+
+type Word struct {
+	Text  string `bun:",pk"`
+	Count int
+}
+
+func TestEscapedKey(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.AddResource(brest.NewResource("Word", (*Word)(nil), brest.All))
+	db.ResetModel(context.Background(), (*Word)(nil))
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	req, err := http.NewRequest("PUT", ts.URL+"/rest/Word/hello%20world%2Fagain", bytes.NewBufferString("{\"Count\":2}"))
+	assert.Nil(t, err)
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "/rest/Word/hello%20world%2Fagain", res.Header.Get("Location"))
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	word := new(Word)
+	err = db.NewSelect().Model(word).Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world/again", word.Text)
+	assert.Equal(t, 2, word.Count)
+}
+
+func TestCompositeKey(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.AddResource(brest.NewResource("BookTag", (*BookTag)(nil), brest.All))
+	db.ResetModel(context.Background(), (*BookTag)(nil))
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var err error
+	var req *http.Request
+	var body []byte
+	var res *http.Response
+	var resBookTag *BookTag
+
+	req, err = http.NewRequest("POST", ts.URL+"/rest/BookTag", bytes.NewBufferString("{\"BookID\":12,\"Tag\":\"sci,fi\",\"Weight\":1}"))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "/rest/BookTag/12,sci%2Cfi", res.Header.Get("Location"))
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/BookTag/12,sci%2Cfi", nil)
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	resBookTag = &BookTag{}
+	err = json.Unmarshal(body, resBookTag)
+	assert.Nil(t, err)
+	assert.Equal(t, "sci,fi", resBookTag.Tag)
+	assert.Equal(t, 1, resBookTag.Weight)
+
+	req, err = http.NewRequest("PATCH", ts.URL+"/rest/BookTag/;tag=sci%2Cfi;book_id=12", bytes.NewBufferString("{\"Weight\":5}"))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	req, err = http.NewRequest("PUT", ts.URL+"/rest/BookTag/13,novel", bytes.NewBufferString("{\"Weight\":2}"))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "/rest/BookTag/13,novel", res.Header.Get("Location"))
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/BookTag/;book=12;tag=sci%2Cfi", nil)
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	resBookTag = &BookTag{}
+	err = json.Unmarshal(body, resBookTag)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, resBookTag.Weight)
+
+	req, err = http.NewRequest("DELETE", ts.URL+"/rest/BookTag/12,sci%2Cfi", nil)
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	bookTags := make([]BookTag, 0)
+	err = db.NewSelect().Model(&bookTags).Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(bookTags))
+	assert.Equal(t, 13, bookTags[0].BookID)
+	assert.Equal(t, 2, bookTags[0].Weight)
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/BookTag/12", nil)
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+}
@@ -0,0 +1,158 @@
+package brest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// ComputedFunc computes value of computed field from loaded entity (pointer to resource struct)
+type ComputedFunc func(ctx context.Context, entity interface{}) (interface{}, error)
+
+// ComputedField structure
+type ComputedField struct {
+	Name        string       // Go name of struct field receiving value
+	Expr        string       // SQL expression (?TableAlias may be used), struct field must be tagged `bun:",scanonly"`
+	Func        ComputedFunc // function run after load, struct field must be tagged `bun:"-"`
+	Depends     []string     // fields (Go or column names) loaded for function when fields are selected
+	Description string       // description for documentation, exposed by ComputedFields
+}
+
+func (f *ComputedField) String() string {
+	if f.Func != nil {
+		return fmt.Sprintf("<name=%v func>", f.Name)
+	}
+	return fmt.Sprintf("<name=%v expr=%v>", f.Name, f.Expr)
+}
+
+// AddComputedExpr declares field computed by SQL expression in select queries, field can be filtered and sorted
+func (r *Resource) AddComputedExpr(name string, expr string, description string) {
+	r.computedFields = append(r.computedFields, &ComputedField{Name: name, Expr: expr, Description: description})
+}
+
+// AddComputedFunc declares field computed by function after load, depends are the fields used by function,
+// loaded even if not selected (function only sees loaded fields)
+func (r *Resource) AddComputedFunc(name string, fn ComputedFunc, description string, depends ...string) {
+	r.computedFields = append(r.computedFields, &ComputedField{Name: name, Func: fn, Depends: depends, Description: description})
+}
+
+// ComputedFields returns computed fields
+func (r *Resource) ComputedFields() []*ComputedField {
+	return r.computedFields
+}
+
+// computedField returns computed field by Go or column name, nil if not computed
+func (r *Resource) computedField(name string) *ComputedField {
+	if r == nil {
+		return nil
+	}
+	// column names are matched ignoring underscores (book_count for BookCount)
+	for _, computed := range r.computedFields {
+		if strings.EqualFold(computed.Name, name) || strings.EqualFold(strings.ReplaceAll(name, "_", ""), computed.Name) {
+			return computed
+		}
+	}
+	return nil
+}
+
+// computedColumn returns column name of scan only field of computed SQL expression
+func computedColumn(table *schema.Table, computed *ComputedField) (string, error) {
+	for name, field := range table.FieldMap {
+		if field.GoName == computed.Name {
+			return name, nil
+		}
+	}
+	return "", NewErrorBadRequest(fmt.Sprintf("unknown computed field '%v' for resource '%v'", computed.Name, table.TypeName))
+}
+
+// requested returns true if computed field is selected by fields (all fields if empty)
+func (f *ComputedField) requested(resource *Resource, fields []*Field) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, field := range fields {
+		if resource.computedField(field.Name) == f {
+			return true
+		}
+	}
+	return false
+}
+
+// addQuerySelection adds selected columns and computed SQL expressions to select query
+func addQuerySelection(query *bun.SelectQuery, resource *Resource, fields []*Field) (*bun.SelectQuery, error) {
+	columns := make([]*Field, 0, len(fields))
+	for _, field := range fields {
+		computed := resource.computedField(field.Name)
+		if computed == nil {
+			columns = append(columns, field)
+			continue
+		}
+		for _, depend := range computed.Depends {
+			if !hasField(columns, depend) {
+				columns = append(columns, &Field{Name: depend})
+			}
+		}
+	}
+	if resource == nil {
+		return addQueryFields(query, columns), nil
+	}
+	q := addQueryFields(query, selectedColumns(query.DB().Table(resource.ResourceType()), columns))
+	allColumns := len(fields) == 0
+	for _, computed := range resource.computedFields {
+		if computed.Expr == "" || !computed.requested(resource, fields) {
+			continue
+		}
+		table := q.DB().Table(resource.ResourceType())
+		column, err := computedColumn(table, computed)
+		if err != nil {
+			return nil, err
+		}
+		if allColumns {
+			// columns of table must be selected explicitly once an expression is added
+			for _, field := range table.Fields {
+				q = q.Column(field.Name)
+			}
+			allColumns = false
+		}
+		q = q.ColumnExpr("("+computed.Expr+") AS ?", bun.Ident(column))
+	}
+	return q, nil
+}
+
+// computeFields sets fields of entity computed by functions and selected by fields (all fields if empty)
+func (r *Resource) computeFields(ctx context.Context, fields []*Field, entity interface{}) error {
+	if r == nil {
+		return nil
+	}
+	elem := reflect.Indirect(reflect.ValueOf(entity))
+	if elem.Kind() != reflect.Struct {
+		return nil
+	}
+	for _, computed := range r.computedFields {
+		if computed.Func == nil || !computed.requested(r, fields) {
+			continue
+		}
+		value, err := computed.Func(ctx, entity)
+		if err != nil {
+			return err
+		}
+		field := elem.FieldByName(computed.Name)
+		if !field.IsValid() || !field.CanSet() {
+			return fmt.Errorf("unknown computed field '%v' for resource '%v'", computed.Name, r.name)
+		}
+		if value == nil {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+		v := reflect.ValueOf(value)
+		if !v.Type().ConvertibleTo(field.Type()) {
+			return fmt.Errorf("computed value of type '%v' not assignable to field '%v' of resource '%v'", v.Type(), computed.Name, r.name)
+		}
+		field.Set(v.Convert(field.Type()))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package brest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+type AuthorStats struct {
+	bun.BaseModel `bun:"table:authors"`
+	ID            int `bun:",pk,autoincrement"`
+	Firstname     string
+	Lastname      string
+	FullName      string `bun:"-"`
+	BookCount     int    `bun:",scanonly"`
+}
+
+func initComputedTests(t *testing.T) (*bun.DB, *brest.Config) {
+	db, config := initTests(t)
+	resource := brest.NewResource("AuthorStats", (*AuthorStats)(nil), brest.All)
+	resource.AddComputedExpr("BookCount", "SELECT count(*) FROM books AS b WHERE b.author_id = ?TableAlias.id", "number of books of author")
+	resource.AddComputedFunc("FullName", func(ctx context.Context, entity interface{}) (interface{}, error) {
+		author := entity.(*AuthorStats)
+		return author.Firstname + " " + author.Lastname, nil
+	}, "first name followed by last name", "Firstname", "Lastname")
+	config.AddResource(resource)
+	for _, author := range authors {
+		_, err := db.NewInsert().Model(&author).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+	for _, book := range books {
+		_, err := db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+	return db, config
+}
+
+func TestComputedFields(t *testing.T) {
+	db, config := initComputedTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	assert.Equal(t, 2, len(config.GetResource("AuthorStats").ComputedFields()))
+	assert.Equal(t, "number of books of author", config.GetResource("AuthorStats").ComputedFields()[0].Description)
+	assert.Equal(t, []string{"Firstname", "Lastname"}, config.GetResource("AuthorStats").ComputedFields()[1].Depends)
+
+	res, err := engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "AuthorStats", Key: "2"})
+	assert.Nil(t, err)
+	author := res.(*AuthorStats)
+	assert.Equal(t, "Kafka", author.Lastname)
+	assert.Equal(t, 5, author.BookCount)
+	assert.Equal(t, "Franz Kafka", author.FullName)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "AuthorStats", Sorts: []*brest.Sort{{Name: "BookCount", Asc: true}}})
+	assert.Nil(t, err)
+	slice := *res.(*brest.Page).Slice.(*[]AuthorStats)
+	assert.Equal(t, 3, len(slice))
+	assert.Equal(t, "Fitzgerald", slice[0].Lastname)
+	assert.Equal(t, 1, slice[0].BookCount)
+	assert.Equal(t, "Francis Scott Key Fitzgerald", slice[0].FullName)
+	assert.Equal(t, 6, slice[2].BookCount)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "AuthorStats", Filter: &brest.Filter{Op: brest.Gte, Attr: "book_count", Value: 5}, Sorts: []*brest.Sort{{Name: "book_count", Asc: false}}})
+	assert.Nil(t, err)
+	slice = *res.(*brest.Page).Slice.(*[]AuthorStats)
+	assert.Equal(t, 2, len(slice))
+	assert.Equal(t, "de Saint Exupéry", slice[0].Lastname)
+	assert.Equal(t, 2, res.(*brest.Page).Count)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "AuthorStats", Key: "1", Fields: []*brest.Field{{Name: "ID"}, {Name: "Lastname"}, {Name: "BookCount"}}})
+	assert.Nil(t, err)
+	author = res.(*AuthorStats)
+	assert.Equal(t, "", author.Firstname)
+	assert.Equal(t, 6, author.BookCount)
+	assert.Equal(t, "", author.FullName)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "AuthorStats", Key: "1", Fields: []*brest.Field{{Name: "FullName"}}})
+	assert.Nil(t, err)
+	author = res.(*AuthorStats)
+	assert.Equal(t, 0, author.BookCount)
+	assert.Equal(t, "Antoine de Saint Exupéry", author.FullName)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "AuthorStats", Filter: &brest.Filter{Op: brest.Eq, Attr: "FullName", Value: "Franz Kafka"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+}
+
+func TestServerComputedFields(t *testing.T) {
+	db, config := initComputedTests(t)
+	defer db.Close()
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/rest/AuthorStats?sort=-BookCount&fields=Lastname,BookCount,FullName&filter="+url.QueryEscape(`{"Op":"lt","Attr":"BookCount","Value":6}`), bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	page := struct {
+		Slice []AuthorStats `json:"slice"`
+		Count int           `json:"count"`
+	}{}
+	err = json.Unmarshal(body, &page)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, page.Count)
+	assert.Equal(t, "Kafka", page.Slice[0].Lastname)
+	assert.Equal(t, 5, page.Slice[0].BookCount)
+	// Firstname isn't selected but is loaded for function
+	assert.Equal(t, "Franz Kafka", page.Slice[0].FullName)
+	assert.Equal(t, "", page.Slice[0].Firstname)
+}
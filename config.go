@@ -17,13 +17,36 @@ type BeforeHook func(ctx context.Context, restQuery *RestQuery, entity interface
 // AfterHook defines after execution callback function
 type AfterHook func(ctx context.Context, restQuery *RestQuery, entity interface{}) error
 
+// ExecFuncFactory defines function building execution function from executor state
+type ExecFuncFactory func(executor *Executor) ExecFunc
+
+// PutMode type
+type PutMode string
+
+const (
+	// PutUpsert creates entity if missing, replaces it otherwise
+	PutUpsert PutMode = "Upsert"
+	// PutUpdate replaces existing entity, not found error is returned if missing
+	PutUpdate PutMode = "Update"
+)
+
 // Resource structure
 type Resource struct {
-	name         string
-	resourceType reflect.Type
-	action       Action
-	beforeHook   BeforeHook
-	afterHook    AfterHook
+	name              string
+	resourceType      reflect.Type
+	action            Action
+	hooks             hooks
+	queryHooks        queryHooks
+	execFuncFactories map[Action]ExecFuncFactory
+	virtual           VirtualResource
+	putMode           PutMode
+	nestedRelations   map[string]Orphan
+	alternateKeys     []string
+	lookupField       string
+	search            search
+	geometryField     string
+	geometrySRID      int
+	computedFields    []*ComputedField
 }
 
 func (r *Resource) String() string {
@@ -47,6 +70,66 @@ func (r *Resource) Action() Action {
 	return r.action
 }
 
+// SetPutMode sets put mode
+func (r *Resource) SetPutMode(putMode PutMode) {
+	r.putMode = putMode
+}
+
+// PutMode returns put mode
+func (r *Resource) PutMode() PutMode {
+	return r.putMode
+}
+
+// AddNestedRelation enables nested writes of relation (Go field name) on Post, Put and Patch,
+// orphan defines behaviour for children omitted from a Put
+func (r *Resource) AddNestedRelation(name string, orphan Orphan) {
+	r.nestedRelations[name] = orphan
+}
+
+// AddAlternateKey declares unique field (Go or column name) addressing entities with "field:value" keys,
+// Put with missing alternate key returns not found error instead of creating entity
+func (r *Resource) AddAlternateKey(field string) {
+	r.alternateKeys = append(r.alternateKeys, field)
+}
+
+// SetLookupField sets unique field (Go or column name) used instead of pk for keys without "field:" prefix
+func (r *Resource) SetLookupField(field string) {
+	r.lookupField = field
+}
+
+// LookupField returns lookup field, empty if keys designate pk
+func (r *Resource) LookupField() string {
+	return r.lookupField
+}
+
+// SetGeometryField sets geometry field (Go or column name) used by GeoJSON output and bbox parameter
+func (r *Resource) SetGeometryField(field string) {
+	r.geometryField = field
+}
+
+// GeometryField returns geometry field, empty if resource isn't spatial
+func (r *Resource) GeometryField() string {
+	return r.geometryField
+}
+
+// SetGeometrySRID sets SRID of geometry field used when geometries carry none (4326 by default)
+func (r *Resource) SetGeometrySRID(srid int) {
+	r.geometrySRID = srid
+}
+
+// GeometrySRID returns SRID of geometry field
+func (r *Resource) GeometrySRID() int {
+	if r.geometrySRID == 0 {
+		return 4326
+	}
+	return r.geometrySRID
+}
+
+// Virtual returns virtual resource, nil if resource is backed by a bun model
+func (r *Resource) Virtual() VirtualResource {
+	return r.virtual
+}
+
 // NewResource constructs Resource
 func NewResource(name string, entity interface{}, action Action) *Resource {
 	r := new(Resource)
@@ -56,32 +139,93 @@ func NewResource(name string, entity interface{}, action Action) *Resource {
 		r.resourceType = r.resourceType.Elem()
 	}
 	r.action = action
+	r.hooks = make(hooks)
+	r.execFuncFactories = make(map[Action]ExecFuncFactory)
+	r.putMode = PutUpsert
+	r.nestedRelations = make(map[string]Orphan)
+	return r
+}
+
+// NewVirtualResource constructs Resource not backed by a bun model, entity type is used for deserialization
+func NewVirtualResource(name string, entity interface{}, virtual VirtualResource, action Action) *Resource {
+	r := NewResource(name, entity, action)
+	r.virtual = virtual
 	return r
 }
 
 // NewResourceWithHooks constructs Resource with hooks
 func NewResourceWithHooks(name string, entity interface{}, action Action, beforeHook BeforeHook, afterHook AfterHook) *Resource {
-	r := new(Resource)
-	r.name = name
-	r.resourceType = reflect.TypeOf(entity)
-	if r.resourceType.Kind() == reflect.Ptr {
-		r.resourceType = r.resourceType.Elem()
+	r := NewResource(name, entity, action)
+	if beforeHook != nil {
+		for _, event := range BeforeEvents {
+			r.AddHook(event, Hook(beforeHook))
+		}
+	}
+	if afterHook != nil {
+		for _, event := range AfterEvents {
+			r.AddHook(event, Hook(afterHook))
+		}
 	}
-	r.action = action
-	r.beforeHook = beforeHook
-	r.afterHook = afterHook
 	return r
 }
 
+// AddHook adds hook for event, hooks are called in order of addition
+func (r *Resource) AddHook(event Event, hook Hook) {
+	r.hooks.add(event, hook)
+}
+
+// SetExecFuncFactory sets factory used instead of default execution function for each action of mask
+//
+// For Get, the factory is used for both one and slice queries (key is empty for slice).
+// For Patch, the factory replaces the update step (executed after loading the entity for JSON Patch and JSON Merge Patch).
+func (r *Resource) SetExecFuncFactory(action Action, factory ExecFuncFactory) {
+	for _, a := range []Action{Get, Post, Put, Patch, Delete} {
+		if action&a != 0 {
+			r.execFuncFactories[a] = factory
+		}
+	}
+}
+
+// execFunc returns custom execution function for action or default execution function
+func (r *Resource) execFunc(action Action, executor *Executor, defaultExecFunc ExecFunc) ExecFunc {
+	if factory, ok := r.execFuncFactories[action]; ok {
+		return factory(executor)
+	}
+	return defaultExecFunc
+}
+
+// AddSelectQueryHook adds hook modifying select queries
+func (r *Resource) AddSelectQueryHook(hook SelectQueryHook) {
+	r.queryHooks.selectHooks = append(r.queryHooks.selectHooks, hook)
+}
+
+// AddInsertQueryHook adds hook modifying insert queries
+func (r *Resource) AddInsertQueryHook(hook InsertQueryHook) {
+	r.queryHooks.insertHooks = append(r.queryHooks.insertHooks, hook)
+}
+
+// AddUpdateQueryHook adds hook modifying update queries
+func (r *Resource) AddUpdateQueryHook(hook UpdateQueryHook) {
+	r.queryHooks.updateHooks = append(r.queryHooks.updateHooks, hook)
+}
+
+// AddDeleteQueryHook adds hook modifying delete queries
+func (r *Resource) AddDeleteQueryHook(hook DeleteQueryHook) {
+	r.queryHooks.deleteHooks = append(r.queryHooks.deleteHooks, hook)
+}
+
 // Config structure
 type Config struct {
 	prefix             string
 	db                 *bun.DB
 	resources          map[string]*Resource
+	hooks              hooks
+	queryHooks         queryHooks
 	defaultContentType string
 	defaultAccept      string
 	infoLogger         *log.Logger
 	errorLogger        *log.Logger
+	sqliteFunctions    bool
 }
 
 func (c *Config) String() string {
@@ -90,6 +234,10 @@ func (c *Config) String() string {
 
 // AddResource adds resource
 func (c *Config) AddResource(resource *Resource) {
+	if resource.Virtual() != nil {
+		c.resources[resource.Name()] = resource
+		return
+	}
 	elem := reflect.New(resource.ResourceType()).Elem()
 	entity := elem.Addr().Interface()
 	c.db.RegisterModel(entity)
@@ -101,6 +249,42 @@ func (c *Config) GetResource(resourceName string) *Resource {
 	return c.resources[resourceName]
 }
 
+// getResourceByType gets first resource (by name) of type
+func (c *Config) getResourceByType(resourceType reflect.Type) *Resource {
+	var found *Resource
+	for _, resource := range c.resources {
+		if resource.ResourceType() == resourceType && resource.Virtual() == nil && (found == nil || resource.Name() < found.Name()) {
+			found = resource
+		}
+	}
+	return found
+}
+
+// AddHook adds global hook for event, global hooks are applied to all resources
+func (c *Config) AddHook(event Event, hook Hook) {
+	c.hooks.add(event, hook)
+}
+
+// AddSelectQueryHook adds global hook modifying select queries of all resources
+func (c *Config) AddSelectQueryHook(hook SelectQueryHook) {
+	c.queryHooks.selectHooks = append(c.queryHooks.selectHooks, hook)
+}
+
+// AddInsertQueryHook adds global hook modifying insert queries of all resources
+func (c *Config) AddInsertQueryHook(hook InsertQueryHook) {
+	c.queryHooks.insertHooks = append(c.queryHooks.insertHooks, hook)
+}
+
+// AddUpdateQueryHook adds global hook modifying update queries of all resources
+func (c *Config) AddUpdateQueryHook(hook UpdateQueryHook) {
+	c.queryHooks.updateHooks = append(c.queryHooks.updateHooks, hook)
+}
+
+// AddDeleteQueryHook adds global hook modifying delete queries of all resources
+func (c *Config) AddDeleteQueryHook(hook DeleteQueryHook) {
+	c.queryHooks.deleteHooks = append(c.queryHooks.deleteHooks, hook)
+}
+
 // SetPrefix sets prefix
 func (c *Config) SetPrefix(prefix string) {
 	c.prefix = prefix
@@ -165,12 +349,24 @@ func (c *Config) ErrorLogger() *log.Logger {
 	return c.errorLogger
 }
 
+// SetSQLiteFunctions declares whether unaccent and regexp SQL functions are registered with SQLite driver of db,
+// Lulk, Nlulk, Sim and Nsim operations return bad request error on SQLite otherwise (see package sqlitefunc)
+func (c *Config) SetSQLiteFunctions(enabled bool) {
+	c.sqliteFunctions = enabled
+}
+
+// SQLiteFunctions returns true if unaccent and regexp SQL functions are registered with SQLite driver of db
+func (c *Config) SQLiteFunctions() bool {
+	return c.sqliteFunctions
+}
+
 // NewConfig constructs Config
 func NewConfig(prefix string, db *bun.DB) *Config {
 	c := new(Config)
 	c.SetPrefix(prefix)
 	c.db = db
 	c.resources = make(map[string]*Resource)
+	c.hooks = make(hooks)
 	c.defaultContentType = Json
 	c.defaultAccept = Json
 	c.infoLogger = log.New(os.Stdout, " INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
@@ -0,0 +1,243 @@
+package brest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+// ConstraintKind database constraint kind type
+type ConstraintKind string
+
+const (
+	// Unique constraint (unique index or primary key)
+	Unique ConstraintKind = "unique"
+	// ForeignKey constraint
+	ForeignKey ConstraintKind = "foreign key"
+	// NotNull constraint
+	NotNull ConstraintKind = "not null"
+	// Check constraint
+	Check ConstraintKind = "check"
+)
+
+// ConstraintError structure describes a database constraint violation
+type ConstraintError struct {
+	Kind       ConstraintKind
+	Constraint string
+	Column     string
+	Cause      error
+}
+
+// Error implements the error interface
+func (e ConstraintError) Error() string {
+	msg := string(e.Kind) + " constraint violation"
+	if e.Constraint != "" {
+		msg += fmt.Sprintf(" on constraint '%v'", e.Constraint)
+	}
+	if e.Column != "" {
+		msg += fmt.Sprintf(" on column '%v'", e.Column)
+	}
+	return msg
+}
+
+// Unwrap returns cause
+func (e ConstraintError) Unwrap() error {
+	return e.Cause
+}
+
+// StatusCode returns 409 for unique and foreign key violations, 422 otherwise
+func (e ConstraintError) StatusCode() int {
+	if e.Kind == Unique || e.Kind == ForeignKey {
+		return 409
+	}
+	return 422
+}
+
+// translateConstraintError translates driver error of dialect into ConstraintError, returns nil if error isn't a constraint violation
+func translateConstraintError(name dialect.Name, err error) *ConstraintError {
+	switch name {
+	case dialect.PG:
+		return translatePgError(err)
+	case dialect.SQLite:
+		return translateSqliteError(err)
+	case dialect.MySQL:
+		return translateMysqlError(err)
+	}
+	return nil
+}
+
+// translateError translates constraint violation cause of error into Error with 409 or 422 code,
+// error is returned unchanged otherwise
+func translateError(name dialect.Name, err error) error {
+	if err == nil {
+		return nil
+	}
+	var rerr *Error
+	if errors.As(err, &rerr) && rerr.StatusCode() != 500 {
+		return err
+	}
+	if cerr := translateConstraintError(name, err); cerr != nil {
+		return &Error{Message: "database constraint violated", Cause: cerr, Code: cerr.StatusCode()}
+	}
+	return err
+}
+
+// pgKinds maps Postgres SQLSTATE to constraint kind
+var pgKinds = map[string]ConstraintKind{
+	"23505": Unique,
+	"23503": ForeignKey,
+	"23502": NotNull,
+	"23514": Check,
+}
+
+// translatePgError translates Postgres errors (pgdriver, pgx and lib/pq)
+func translatePgError(err error) *ConstraintError {
+	var fieldErr interface{ Field(byte) string }
+	if errors.As(err, &fieldErr) {
+		if kind, ok := pgKinds[fieldErr.Field('C')]; ok {
+			return &ConstraintError{Kind: kind, Constraint: fieldErr.Field('n'), Column: fieldErr.Field('c'), Cause: err}
+		}
+		return nil
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		if kind, ok := pgKinds[stateErr.SQLState()]; ok {
+			return &ConstraintError{
+				Kind:       kind,
+				Constraint: stringField(stateErr, "ConstraintName", "Constraint"),
+				Column:     stringField(stateErr, "ColumnName", "Column"),
+				Cause:      err,
+			}
+		}
+	}
+	return nil
+}
+
+// sqliteKinds maps SQLite extended result codes to constraint kind
+var sqliteKinds = map[int]ConstraintKind{
+	2067: Unique,
+	1555: Unique,
+	787:  ForeignKey,
+	1299: NotNull,
+	275:  Check,
+}
+
+var sqliteMessage = regexp.MustCompile(`(UNIQUE|FOREIGN KEY|NOT NULL|CHECK) constraint failed(?::\s*([^ (]+(?:, [^ (]+)*))?`)
+
+// translateSqliteError translates SQLite errors (modernc and mattn drivers)
+func translateSqliteError(err error) *ConstraintError {
+	var codeErr interface{ Code() int }
+	isSqlite := errors.As(err, &codeErr)
+	if !isSqlite {
+		isSqlite = intField(err, "ExtendedCode") != 0
+	}
+	if !isSqlite {
+		return nil
+	}
+	var kind ConstraintKind
+	var subject string
+	if codeErr != nil {
+		kind = sqliteKinds[codeErr.Code()]
+	} else {
+		kind = sqliteKinds[intField(err, "ExtendedCode")]
+	}
+	if res := sqliteMessage.FindStringSubmatch(err.Error()); res != nil {
+		if kind == "" {
+			kind = ConstraintKind(strings.ToLower(res[1]))
+		}
+		subject = strings.TrimSpace(res[2])
+	}
+	if kind == "" {
+		return nil
+	}
+	cerr := &ConstraintError{Kind: kind, Cause: err}
+	if kind == Check {
+		cerr.Constraint = subject
+	} else {
+		cerr.Column = sqliteColumns(subject)
+	}
+	return cerr
+}
+
+// sqliteColumns strips table prefix of columns listed by SQLite message ("tags.name, tags.label")
+func sqliteColumns(subject string) string {
+	if subject == "" {
+		return ""
+	}
+	columns := strings.Split(subject, ", ")
+	for i, column := range columns {
+		if index := strings.LastIndex(column, "."); index != -1 {
+			columns[i] = column[index+1:]
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// mysqlKinds maps MySQL error numbers to constraint kind
+var mysqlKinds = map[int]ConstraintKind{
+	1062: Unique,
+	1451: ForeignKey,
+	1452: ForeignKey,
+	1048: NotNull,
+	1364: NotNull,
+	3819: Check,
+}
+
+var mysqlKey = regexp.MustCompile(`for key '([^']+)'|CONSTRAINT ` + "`([^`]+)`" + `|[Cc]heck constraint '([^']+)'`)
+
+var mysqlColumn = regexp.MustCompile(`[Cc]olumn '([^']+)'|[Ff]ield '([^']+)'`)
+
+// translateMysqlError translates MySQL errors (go-sql-driver)
+func translateMysqlError(err error) *ConstraintError {
+	kind, ok := mysqlKinds[intField(err, "Number")]
+	if !ok {
+		return nil
+	}
+	cerr := &ConstraintError{Kind: kind, Cause: err}
+	if res := mysqlKey.FindStringSubmatch(err.Error()); res != nil {
+		cerr.Constraint = res[1] + res[2] + res[3]
+	}
+	if res := mysqlColumn.FindStringSubmatch(err.Error()); res != nil {
+		cerr.Column = res[1] + res[2]
+	}
+	return cerr
+}
+
+// stringField returns first non empty string field among names of error struct
+func stringField(err interface{}, names ...string) string {
+	v := reflect.Indirect(reflect.ValueOf(err))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	for _, name := range names {
+		if f := v.FieldByName(name); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+			return f.String()
+		}
+	}
+	return ""
+}
+
+// intField returns integer field of error chain struct, 0 if not found
+func intField(err error, name string) int {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		f := v.FieldByName(name)
+		if !f.IsValid() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return int(f.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(f.Uint())
+		}
+	}
+	return 0
+}
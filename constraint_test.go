@@ -0,0 +1,80 @@
+package brest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+type Tag struct {
+	ID    int     `bun:",pk,autoincrement"`
+	Name  string  `bun:",unique"`
+	Label *string `bun:",notnull"`
+}
+
+func TestConstraintError(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.AddResource(brest.NewResource("Tag", (*Tag)(nil), brest.All))
+	db.ResetModel(context.Background(), (*Tag)(nil))
+	engine := brest.NewEngine(config)
+
+	var err error
+	var cerr *brest.ConstraintError
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Tag", ContentType: brest.Json, Content: []byte("{\"Name\":\"tag\",\"Label\":\"label\"}")})
+	assert.Nil(t, err)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Tag", ContentType: brest.Json, Content: []byte("{\"Name\":\"tag\",\"Label\":\"label\"}")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 409, err.(*brest.Error).StatusCode())
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, brest.Unique, cerr.Kind)
+	assert.Equal(t, "name", cerr.Column)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Tag", ContentType: brest.Json, Content: []byte("{\"Name\":\"other\"}")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 422, err.(*brest.Error).StatusCode())
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, brest.NotNull, cerr.Kind)
+	assert.Equal(t, "label", cerr.Column)
+}
+
+func TestConstraintErrorM2M(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	db.RegisterModel((*ArticleLabel)(nil))
+	db.ResetModel(context.Background(), (*Article)(nil), (*Label)(nil), (*ArticleLabel)(nil))
+	config.AddResource(brest.NewResource("Article", (*Article)(nil), brest.All))
+	config.AddResource(brest.NewResource("Label", (*Label)(nil), brest.All))
+	config.AddResource(brest.NewResource("Tag", (*Tag)(nil), brest.All))
+	db.ResetModel(context.Background(), (*Tag)(nil))
+	engine := brest.NewEngine(config)
+
+	// a label may be linked to one article only
+	_, err := db.NewCreateIndex().Model((*ArticleLabel)(nil)).Unique().Index("article_labels_label_idx").Column("label_id").Exec(context.Background())
+	assert.Nil(t, err)
+	for _, content := range []string{`{"Title":"Go"}`, `{"Title":"Rust"}`} {
+		_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Article", ContentType: brest.Json, Content: []byte(content)})
+		assert.Nil(t, err)
+	}
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Label", ContentType: brest.Json, Content: []byte(`{"Name":"lang"}`)})
+	assert.Nil(t, err)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Put, Resource: "Article", Key: "1", SubResource: "Labels", SubKey: "1"})
+	assert.Nil(t, err)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Put, Resource: "Article", Key: "2", SubResource: "Labels", SubKey: "1"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 409, err.(*brest.Error).StatusCode())
+	var cerr *brest.ConstraintError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, brest.Unique, cerr.Kind)
+
+	// batch operations
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: brest.BatchResource, ContentType: brest.Json, Content: []byte(`[{"method":"POST","resource":"Tag","body":{"Name":"tag","Label":"a"}},{"method":"POST","resource":"Tag","body":{"Name":"tag","Label":"b"}}]`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 409, err.(*brest.Error).StatusCode())
+}
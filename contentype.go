@@ -7,4 +7,12 @@ const (
 	Json = "application/json"
 	// Msgpack content type
 	Msgpack = "application/x-msgpack"
+	// GeoJson GeoJSON (RFC 7946) content type
+	GeoJson = "application/geo+json"
+	// Mvt Mapbox Vector Tile content type
+	Mvt = "application/vnd.mapbox-vector-tile"
+	// MergePatchJson JSON Merge Patch (RFC 7396) content type
+	MergePatchJson = "application/merge-patch+json"
+	// JsonPatch JSON Patch (RFC 6902) content type
+	JsonPatch = "application/json-patch+json"
 )
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"reflect"
 	"regexp"
 	"strings"
@@ -32,20 +33,61 @@ func (e *Engine) Config() *Config {
 
 // Execute executes a rest query
 func (e *Engine) Execute(restQuery *RestQuery) (interface{}, error) {
+	res, _, err := e.execute(restQuery)
+	return res, err
+}
+
+// execute executes a rest query and returns result with http status code
+func (e *Engine) execute(restQuery *RestQuery) (interface{}, int, error) {
+	return e.executeContext(e.context(restQuery), restQuery)
+}
+
+// executeContext executes a rest query in context and returns result with http status code,
+// constraint violations are translated into errors with 409 or 422 code whatever the path
+func (e *Engine) executeContext(ctx context.Context, restQuery *RestQuery) (interface{}, int, error) {
+	res, status, err := e.executeQuery(ctx, restQuery)
+	if err != nil {
+		return nil, 0, NewErrorFromCause(translateError(e.config.DB().Dialect().Name(), err))
+	}
+	return res, status, nil
+}
+
+// executeQuery executes a rest query in context and returns result with http status code
+func (e *Engine) executeQuery(ctx context.Context, restQuery *RestQuery) (interface{}, int, error) {
+	if restQuery.Resource == BatchResource {
+		return e.executeBatch(ctx, restQuery)
+	}
 	resource, err := e.getResource(restQuery)
 	if err != nil {
-		return nil, NewErrorFromCause(err)
+		return nil, 0, NewErrorFromCause(err)
+	}
+	if restQuery.SubResource != "" {
+		return e.executeSubResource(ctx, restQuery, resource)
 	}
 	if resource.Action()&restQuery.Action == 0 {
-		return nil, NewErrorForbbiden(fmt.Sprintf("query %v not authorized for resource %v", restQuery, resource))
+		return nil, 0, NewErrorForbbiden(fmt.Sprintf("query %v not authorized for resource %v", restQuery, resource))
+	}
+	if resource.Virtual() != nil {
+		res, err := e.executeVirtual(ctx, restQuery, resource)
+		return res, statusCode(restQuery.Action, false), err
+	}
+	if restQuery.Tile != nil {
+		return e.executeTile(ctx, restQuery, resource)
+	}
+	if isBulk(restQuery) {
+		return e.executeBulk(ctx, restQuery, resource)
+	}
+	if restQuery.Action == Get && restQuery.Key == "" && (len(restQuery.Aggregates) > 0 || len(restQuery.GroupBy) > 0) {
+		return e.executeAggregate(ctx, restQuery, resource)
 	}
 	elem := reflect.New(resource.ResourceType()).Elem()
 	entity := elem.Addr().Interface()
 	var slice interface{}
+	var columns []string
 	if restQuery.Action == Get {
 		if restQuery.Key != "" {
-			if err = setPk(e.Config().DB(), resource.ResourceType(), elem, restQuery.Key); err != nil {
-				return nil, NewErrorFromCause(err)
+			if err = e.setKey(ctx, resource, elem, restQuery.Key); err != nil {
+				return nil, 0, NewErrorFromCause(err)
 			}
 		} else {
 			sliceType := reflect.MakeSlice(reflect.SliceOf(resource.ResourceType()), 0, 0).Type()
@@ -53,56 +95,52 @@ func (e *Engine) Execute(restQuery *RestQuery) (interface{}, error) {
 		}
 	} else if restQuery.Action == Post {
 		if restQuery.Key != "" {
-			return nil, NewErrorBadRequest("action 'Post': key is forbidden")
+			return nil, 0, NewErrorBadRequest("action 'Post': key is forbidden")
 		}
 		if err = e.Deserialize(restQuery, resource, entity); err != nil {
-			return nil, NewErrorFromCause(err)
+			return nil, 0, NewErrorFromCause(err)
 		}
+		restQuery.scope.applyAssign(elem)
 	} else if restQuery.Action == Put {
 		if restQuery.Key == "" {
-			return nil, NewErrorBadRequest("action 'Put': key is mandatory")
+			return nil, 0, NewErrorBadRequest("action 'Put': key is mandatory")
 		}
 		if err = e.Deserialize(restQuery, resource, entity); err != nil {
-			return nil, NewErrorFromCause(err)
+			return nil, 0, NewErrorFromCause(err)
+		}
+		if err = e.setKey(ctx, resource, elem, restQuery.Key); err != nil {
+			return nil, 0, NewErrorFromCause(err)
 		}
-		setPk(e.Config().DB(), resource.ResourceType(), elem, restQuery.Key)
+		restQuery.scope.applyAssign(elem)
 	} else if restQuery.Action == Patch {
 		if restQuery.Key == "" {
-			return nil, NewErrorBadRequest("action 'Patch': key is mandatory")
+			return nil, 0, NewErrorBadRequest("action 'Patch': key is mandatory")
 		}
-		if err = setPk(e.Config().DB(), resource.ResourceType(), elem, restQuery.Key); err != nil {
-			return nil, NewErrorFromCause(err)
+		if !isPatchContentType(restQuery.ContentType) {
+			if columns, err = e.payloadColumns(restQuery, resource); err != nil {
+				return nil, 0, NewErrorFromCause(err)
+			}
+		}
+		if err = e.setKey(ctx, resource, elem, restQuery.Key); err != nil {
+			return nil, 0, NewErrorFromCause(err)
 		}
 	} else if restQuery.Action == Delete {
 		if restQuery.Key == "" {
-			return nil, NewErrorBadRequest("action 'Delete': key is mandatory")
+			return nil, 0, NewErrorBadRequest("action 'Delete': key is mandatory")
 		}
-		if err = setPk(e.Config().DB(), resource.ResourceType(), elem, restQuery.Key); err != nil {
-			return nil, NewErrorFromCause(err)
+		if err = e.setKey(ctx, resource, elem, restQuery.Key); err != nil {
+			return nil, 0, NewErrorFromCause(err)
 		}
 	} else {
-		return nil, NewErrorBadRequest(fmt.Sprintf("unknow action '%v'", restQuery.Action))
+		return nil, 0, NewErrorBadRequest(fmt.Sprintf("unknow action '%v'", restQuery.Action))
 	}
 
-	var ctx context.Context
-	if restQuery.Request != nil {
-		ctx = restQuery.Request.Context()
-	}
-	if ctx == nil {
-		ctx = context.Background()
-	}
-	ctx = ContextWithDb(ctx, e.Config().DB())
-
-	if resource.beforeHook != nil {
-		if restQuery.Action == Get && restQuery.Key == "" {
-			if err = resource.beforeHook(ctx, restQuery, entity); err != nil {
-				return nil, NewErrorFromCause(err)
-			}
-		} else {
-			if err = resource.beforeHook(ctx, restQuery, entity); err != nil {
-				return nil, NewErrorFromCause(err)
-			}
+	beforeEvent, afterEvent := hookEvents(restQuery)
+	if err = e.runBeforeHooks(ctx, beforeEvent, restQuery, resource, entity); err != nil {
+		if sc, ok := shortCircuitFromError(err); ok {
+			return sc.Result, statusCode(restQuery.Action, false), nil
 		}
+		return nil, 0, NewErrorFromCause(err)
 	}
 
 	if restQuery.Debug {
@@ -119,30 +157,57 @@ func (e *Engine) Execute(restQuery *RestQuery) (interface{}, error) {
 
 	if restQuery.Action == Get {
 		if restQuery.Key != "" {
-			err = executor.Execute(ctx, executor.GetOneExecFunc())
+			err = executor.Execute(ctx, resource.execFunc(Get, executor, executor.GetOneExecFunc()))
 		} else {
-			err = executor.Execute(ctx, executor.GetSliceExecFunc())
+			err = executor.Execute(ctx, resource.execFunc(Get, executor, executor.GetSliceExecFunc()))
+			if err == nil && len(restQuery.Facets) > 0 {
+				err = executor.Execute(ctx, executor.FacetsExecFunc())
+			}
 		}
 	} else if restQuery.Action == Post {
-		err = executor.Execute(ctx, executor.InsertExecFunc())
+		err = executor.Execute(ctx, executor.NestedExecFunc(resource.execFunc(Post, executor, executor.InsertExecFunc())))
 	} else if restQuery.Action == Put {
-		err = executor.Execute(ctx, executor.UpdateExecFunc())
+		if resource.PutMode() == PutUpdate {
+			err = executor.Execute(ctx, executor.NestedExecFunc(resource.execFunc(Put, executor, executor.UpdateExecFunc())))
+		} else {
+			err = executor.Execute(ctx, executor.NestedExecFunc(resource.execFunc(Put, executor, executor.UpsertExecFunc())))
+		}
+	} else if restQuery.Action == Patch && isPatchContentType(restQuery.ContentType) {
+		err = executor.Execute(ctx, resource.execFunc(Get, executor, executor.GetOneExecFunc()))
+		if err == nil {
+			err = e.afterLoad(ctx, restQuery, resource, entity)
+		}
+		if err == nil {
+			var doc interface{}
+			columns, doc, err = e.deserializePatch(restQuery, resource, entity)
+			executor.SetColumns(columns)
+			executor.SetPayload(doc)
+		}
+		if err == nil {
+			err = e.setKey(ctx, resource, elem, restQuery.Key)
+		}
+		if err == nil {
+			// empty patch is a no-op returning loaded entity
+			err = executor.Execute(ctx, executor.NestedExecFunc(resource.execFunc(Patch, executor, executor.PatchExecFunc())))
+		}
 	} else if restQuery.Action == Patch {
-		err = executor.Execute(ctx, executor.GetOneExecFunc())
+		// content is deserialized after before hooks, they receive entity with key only
+		err = e.Deserialize(restQuery, resource, entity)
 		if err == nil {
-			err = e.Deserialize(restQuery, resource, entity)
+			err = e.setKey(ctx, resource, elem, restQuery.Key)
 		}
 		if err == nil {
-			err = setPk(e.Config().DB(), resource.ResourceType(), elem, restQuery.Key)
+			executor.SetColumns(columns)
+			err = executor.Execute(ctx, executor.NestedExecFunc(resource.execFunc(Patch, executor, executor.PatchExecFunc())))
 		}
 		if err == nil {
-			err = executor.Execute(ctx, executor.UpdateExecFunc())
+			err = e.afterLoad(ctx, restQuery, resource, entity)
 		}
 	} else if restQuery.Action == Delete {
-		err = executor.Execute(ctx, executor.DeleteExecFunc())
+		err = executor.Execute(ctx, resource.execFunc(Delete, executor, executor.DeleteExecFunc()))
 	}
 	if err != nil {
-		return nil, NewErrorFromCause(err)
+		return nil, 0, NewErrorFromCause(err)
 	}
 
 	if restQuery.Debug {
@@ -153,25 +218,38 @@ func (e *Engine) Execute(restQuery *RestQuery) (interface{}, error) {
 		}
 	}
 
-	if resource.afterHook != nil {
-		if restQuery.Action == Get && restQuery.Key == "" {
-			v := reflect.ValueOf(slice).Elem()
-			for i := 0; i < v.Len(); i++ {
-				if err = resource.afterHook(ctx, restQuery, v.Index(i).Addr().Interface()); err != nil {
-					return nil, NewErrorFromCause(err)
-				}
+	if restQuery.Action == Get && restQuery.Key == "" {
+		v := reflect.ValueOf(slice).Elem()
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i).Addr().Interface()
+			if err = e.afterLoad(ctx, restQuery, resource, item); err != nil {
+				break
 			}
-		} else {
-			if err = resource.afterHook(ctx, restQuery, entity); err != nil {
-				return nil, NewErrorFromCause(err)
+			if err = e.runAfterHooks(ctx, afterEvent, restQuery, resource, item); err != nil {
+				break
 			}
 		}
+	} else {
+		if restQuery.Action == Get {
+			err = e.afterLoad(ctx, restQuery, resource, entity)
+		}
+		if err == nil {
+			err = e.runAfterHooks(ctx, afterEvent, restQuery, resource, entity)
+		}
+	}
+	if err != nil {
+		if sc, ok := shortCircuitFromError(err); ok {
+			return sc.Result, statusCode(restQuery.Action, false), nil
+		}
+		return nil, 0, NewErrorFromCause(err)
 	}
 
 	if restQuery.Action == Get && restQuery.Key == "" {
-		return NewPage(executor.entity, executor.count, restQuery), nil
+		page := NewPage(executor.entity, executor.count, restQuery)
+		page.Facets = executor.Facets()
+		return page, statusCode(restQuery.Action, false), nil
 	}
-	return executor.entity, nil
+	return executor.entity, statusCode(restQuery.Action, executor.created), nil
 }
 
 // Deserialize deserializes data into entity
@@ -181,7 +259,13 @@ func (e *Engine) Deserialize(restQuery *RestQuery, resource *Resource, entity in
 	}
 	switch restQuery.Content.(type) {
 	case []byte:
-		if regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.ContentType) {
+		if isPatchContentType(restQuery.ContentType) {
+			if restQuery.Action != Patch {
+				return NewErrorBadRequest(fmt.Sprintf("content type '%v' is only supported for action 'Patch'", restQuery.ContentType))
+			}
+			_, _, err := e.deserializePatch(restQuery, resource, entity)
+			return err
+		} else if regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.ContentType) {
 			if err := json.Unmarshal(restQuery.Content.([]byte), entity); err != nil {
 				return NewErrorFromCause(err)
 			}
@@ -237,6 +321,52 @@ func (e *Engine) Deserialize(restQuery *RestQuery, resource *Resource, entity in
 	return nil
 }
 
+// statusCode returns http status code of successful rest query
+func statusCode(action Action, created bool) int {
+	switch {
+	case action == Post || created:
+		return http.StatusCreated
+	case action == Delete:
+		return http.StatusNoContent
+	default:
+		return http.StatusOK
+	}
+}
+
+// context returns execution context of rest query
+func (e *Engine) context(restQuery *RestQuery) context.Context {
+	var ctx context.Context
+	if restQuery.Request != nil {
+		ctx = restQuery.Request.Context()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ContextWithDb(ctx, e.Config().DB())
+}
+
+// runBeforeHooks runs global hooks, resource hooks then model hook
+func (e *Engine) runBeforeHooks(ctx context.Context, event Event, restQuery *RestQuery, resource *Resource, entity interface{}) error {
+	if err := e.config.hooks.run(ctx, event, restQuery, entity); err != nil {
+		return err
+	}
+	if err := resource.hooks.run(ctx, event, restQuery, entity); err != nil {
+		return err
+	}
+	return runModelHook(ctx, event, restQuery, entity)
+}
+
+// runAfterHooks runs model hook, resource hooks then global hooks
+func (e *Engine) runAfterHooks(ctx context.Context, event Event, restQuery *RestQuery, resource *Resource, entity interface{}) error {
+	if err := runModelHook(ctx, event, restQuery, entity); err != nil {
+		return err
+	}
+	if err := resource.hooks.run(ctx, event, restQuery, entity); err != nil {
+		return err
+	}
+	return e.config.hooks.run(ctx, event, restQuery, entity)
+}
+
 func (e *Engine) getResource(restQuery *RestQuery) (*Resource, error) {
 	if restQuery.Resource == "" {
 		return nil, NewErrorBadRequest("resource is mandatory")
@@ -1,14 +1,19 @@
 package brest_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/vmihailenco/msgpack/v5"
 
 	"github.com/aptogeo/brest"
 	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
 )
 
 func TestDeserialize(t *testing.T) {
@@ -274,3 +279,223 @@ func TestNotAuthorized(t *testing.T) {
 	res, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Todo", Key: strconv.Itoa(resTodo.ID)})
 	assert.NotNil(t, err)
 }
+
+func TestHooks(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+	calls := make([]string, 0)
+
+	config.AddHook(brest.BeforeCreate, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		calls = append(calls, "global before")
+		return nil
+	})
+	config.AddHook(brest.AfterCreate, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		calls = append(calls, "global after")
+		return nil
+	})
+	resource := config.GetResource("Todo")
+	resource.AddHook(brest.BeforeCreate, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		calls = append(calls, "before 1")
+		return nil
+	})
+	resource.AddHook(brest.BeforeCreate, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		calls = append(calls, "before 2")
+		entity.(*Todo).Text += " (hooked)"
+		return nil
+	})
+	resource.AddHook(brest.AfterCreate, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		calls = append(calls, "after")
+		return nil
+	})
+	resource.AddHook(brest.BeforeList, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		return brest.NewShortCircuit(&brest.Page{Count: -1})
+	})
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Todo", ContentType: brest.Form, Content: []byte("Text=Text")})
+	assert.Nil(t, err)
+	assert.NotNil(t, res)
+	assert.Equal(t, "Text (hooked)", res.(*Todo).Text)
+	assert.Equal(t, []string{"global before", "before 1", "before 2", "after", "global after"}, calls)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Todo"})
+	assert.Nil(t, err)
+	assert.Equal(t, -1, res.(*brest.Page).Count)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Todo", Key: strconv.Itoa(1)})
+	assert.Nil(t, err)
+	assert.Equal(t, "Text (hooked)", res.(*Todo).Text)
+}
+
+type Note struct {
+	ID     int `bun:",pk,autoincrement"`
+	Text   string
+	Loaded bool `bun:"-"`
+}
+
+func (n *Note) BeforeRestCreate(ctx context.Context, restQuery *brest.RestQuery) error {
+	if n.Text == "" {
+		return brest.NewErrorBadRequest("text is mandatory")
+	}
+	n.Text = strings.TrimSpace(n.Text)
+	return nil
+}
+
+func (n *Note) AfterLoad(ctx context.Context) error {
+	n.Loaded = true
+	return nil
+}
+
+func TestModelHooks(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.AddResource(brest.NewResource("Note", (*Note)(nil), brest.All))
+	db.ResetModel(context.Background(), (*Note)(nil))
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Note", ContentType: brest.Form, Content: []byte("Text=")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).StatusCode())
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Note", ContentType: brest.Form, Content: []byte("Text= note ")})
+	assert.Nil(t, err)
+	assert.Equal(t, "note", res.(*Note).Text)
+	assert.False(t, res.(*Note).Loaded)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Note", Key: strconv.Itoa(res.(*Note).ID)})
+	assert.Nil(t, err)
+	assert.True(t, res.(*Note).Loaded)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Note"})
+	assert.Nil(t, err)
+	notes := *res.(*brest.Page).Slice.(*[]Note)
+	assert.Equal(t, 1, len(notes))
+	assert.True(t, notes[0].Loaded)
+}
+
+func TestQueryHooks(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	for _, text := range []string{"b", "a", "c", "hidden"} {
+		_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Todo", ContentType: brest.Form, Content: []byte("Text=" + text)})
+		assert.Nil(t, err)
+	}
+
+	resource := config.GetResource("Todo")
+	resource.AddSelectQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.SelectQuery) error {
+		query.Where("text != ?", "hidden")
+		return nil
+	})
+	resource.AddSelectQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.SelectQuery) error {
+		if len(restQuery.Sorts) == 0 {
+			query.Order("text ASC")
+		}
+		return nil
+	})
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Todo"})
+	assert.Nil(t, err)
+	page := res.(*brest.Page)
+	assert.Equal(t, 3, page.Count)
+	resTodos := *page.Slice.(*[]Todo)
+	assert.Equal(t, "a", resTodos[0].Text)
+	assert.Equal(t, "b", resTodos[1].Text)
+	assert.Equal(t, "c", resTodos[2].Text)
+
+	config.AddInsertQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.InsertQuery) error {
+		return brest.NewErrorForbbiden("read only")
+	})
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Todo", ContentType: brest.Form, Content: []byte("Text=d")})
+	assert.NotNil(t, err)
+}
+
+func TestExecFuncFactory(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	resource := config.GetResource("Todo")
+	resource.SetExecFuncFactory(brest.Post, func(executor *brest.Executor) brest.ExecFunc {
+		return func(ctx context.Context, tx *bun.Tx) error {
+			todo := executor.Entity().(*Todo)
+			todo.Text = strings.ToUpper(todo.Text)
+			if _, err := tx.NewInsert().Model(todo).Exec(ctx); err != nil {
+				return err
+			}
+			executor.SetCount(1)
+			return nil
+		}
+	})
+	resource.SetExecFuncFactory(brest.Get, func(executor *brest.Executor) brest.ExecFunc {
+		if executor.RestQuery().Key != "" {
+			return executor.GetOneExecFunc()
+		}
+		return func(ctx context.Context, tx *bun.Tx) error {
+			count, err := tx.NewSelect().Model(executor.Entity()).Where("text LIKE ?", "A%").ScanAndCount(ctx)
+			executor.SetCount(count)
+			return err
+		}
+	})
+
+	for _, text := range []string{"abc", "bcd", "acd"} {
+		res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Todo", ContentType: brest.Form, Content: []byte("Text=" + text)})
+		assert.Nil(t, err)
+		assert.Equal(t, strings.ToUpper(text), res.(*Todo).Text)
+	}
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Todo"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.(*brest.Page).Count)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Todo", Key: "2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "BCD", res.(*Todo).Text)
+}
+
+func TestNotFound(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+
+	config.GetResource("Book").SetPutMode(brest.PutUpdate)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Key: "999"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).StatusCode())
+	assert.True(t, errors.Is(err, brest.ErrNotFound))
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Put, Resource: "Book", Key: "999", ContentType: brest.Json, Content: []byte("{\"Title\":\"title\"}")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).StatusCode())
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "999", ContentType: brest.Json, Content: []byte("{\"Title\":\"title\"}")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).StatusCode())
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Book", Key: "999"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).StatusCode())
+
+	config.GetResource("Book").AddHook(brest.BeforeList, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		return fmt.Errorf("no book: %w", brest.ErrNotFound)
+	})
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).StatusCode())
+}
@@ -1,5 +1,13 @@
 package brest
 
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrNotFound error returned when resource is not found, hooks can return it to respond with not found code
+var ErrNotFound = errors.New("not found")
+
 // Error struct
 type Error struct {
 	Message string
@@ -17,14 +25,33 @@ func NewErrorForbbiden(message string) *Error {
 	return &Error{Message: message, Code: 403}
 }
 
+// NewErrorNotFound constructs Error with not found code
+func NewErrorNotFound(message string) *Error {
+	return &Error{Message: message, Cause: ErrNotFound, Code: 404}
+}
+
+// NewErrorNotAcceptable constructs Error with not acceptable code
+func NewErrorNotAcceptable(message string) *Error {
+	return &Error{Message: message, Code: 406}
+}
+
+// NewErrorConflict constructs Error with conflict code
+func NewErrorConflict(message string) *Error {
+	return &Error{Message: message, Code: 409}
+}
+
 // NewErrorFromCause constructs Error from cause error
 func NewErrorFromCause(cause error) *Error {
-	if err, ok := cause.(*Error); ok {
-		return err
+	var cerr *Error
+	if errors.As(cause, &cerr) {
+		return cerr
 	}
 	if err, ok := cause.(Error); ok {
 		return &err
 	}
+	if errors.Is(cause, ErrNotFound) || errors.Is(cause, sql.ErrNoRows) {
+		return &Error{Message: "resource not found", Cause: cause, Code: 404}
+	}
 	return &Error{Cause: cause, Code: 500}
 }
 
@@ -37,6 +64,11 @@ func (e Error) Error() string {
 	return msg
 }
 
+// Unwrap returns cause
+func (e Error) Unwrap() error {
+	return e.Cause
+}
+
 // StatusCode returns code
 func (e Error) StatusCode() int {
 	if e.Code != 0 {
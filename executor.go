@@ -2,45 +2,134 @@ package brest
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/schema"
 )
 
 // Executor structure
 type Executor struct {
 	config    *Config
+	resource  *Resource
 	restQuery *RestQuery
 	entity    interface{}
 	count     int
 	total     int
+	created   bool
+	columns   []string
+	payload   interface{}
+	facets    map[string][]*FacetValue
 }
 
 // NewExecutor constructs Executor
 func NewExecutor(config *Config, restQuery *RestQuery, entity interface{}) *Executor {
 	e := new(Executor)
 	e.config = config
+	e.resource = config.GetResource(restQuery.Resource)
 	e.restQuery = restQuery
 	e.entity = entity
 	e.count = 0
+	if restQuery.Action == Post || restQuery.Action == Put || restQuery.Action == Patch {
+		e.payload = payloadDocument(restQuery)
+	}
 	return e
 }
 
+// Config gets config
+func (e *Executor) Config() *Config {
+	return e.config
+}
+
+// Resource gets resource
+func (e *Executor) Resource() *Resource {
+	return e.resource
+}
+
+// RestQuery gets rest query
+func (e *Executor) RestQuery() *RestQuery {
+	return e.restQuery
+}
+
+// Entity gets entity (pointer to slice for Get without key)
+func (e *Executor) Entity() interface{} {
+	return e.entity
+}
+
+// Count gets count
+func (e *Executor) Count() int {
+	return e.count
+}
+
+// SetCount sets count
+func (e *Executor) SetCount(count int) {
+	e.count = count
+}
+
+// Created returns true if entity has been created by upsert
+func (e *Executor) Created() bool {
+	return e.created
+}
+
+// SetCreated sets created
+func (e *Executor) SetCreated(created bool) {
+	e.created = created
+}
+
+// Columns gets columns updated by update and patch execution functions, all columns if nil
+func (e *Executor) Columns() []string {
+	return e.columns
+}
+
+// SetColumns sets columns updated by update and patch execution functions
+func (e *Executor) SetColumns(columns []string) {
+	e.columns = columns
+}
+
+// SetPayload sets decoded content (JSON document) telling which fields of nested children are written
+func (e *Executor) SetPayload(payload interface{}) {
+	e.payload = payload
+}
+
+// Facets returns facets computed by FacetsExecFunc
+func (e *Executor) Facets() map[string][]*FacetValue {
+	return e.facets
+}
+
 // Execute executes query
 func (e *Executor) Execute(ctx context.Context, execFunc ExecFunc) error {
-	var err error
-	err = Execute(ctx, func(ctx context.Context, tx *bun.Tx) error {
+	err := Execute(ctx, func(ctx context.Context, tx *bun.Tx) error {
 		return execFunc(ctx, tx)
 	})
+	if err != nil && e.config.DB() != nil {
+		// constraint violations are translated by dialect of database
+		err = translateError(e.config.DB().Dialect().Name(), err)
+	}
 	return err
 }
 
 // GetOneExecFunc gets one execution function
 func (e *Executor) GetOneExecFunc() ExecFunc {
 	return func(ctx context.Context, tx *bun.Tx) error {
-		q := tx.NewSelect().Model(e.entity).WherePK()
-		q = addQueryFields(q, e.restQuery.Fields)
-		q = addQueryRelations(q, e.restQuery.Relations)
+		q, err := addQuerySelection(tx.NewSelect().Model(e.entity).WherePK(), e.resource, e.restQuery.Fields)
+		if err != nil {
+			return NewErrorFromCause(err)
+		}
+		q = addQuerySelectedRelations(q, e.resource, e.restQuery.Fields, e.restQuery.Relations)
+		q = e.restQuery.scope.applyWhere(q)
+		if err := e.runSelectQueryHooks(ctx, q); err != nil {
+			return NewErrorFromCause(err)
+		}
 		count, err := q.ScanAndCount(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return e.notFoundError()
+		}
 		if err != nil {
 			return NewErrorFromCause(err)
 		}
@@ -56,9 +145,19 @@ func (e *Executor) GetSliceExecFunc() ExecFunc {
 		q := tx.NewSelect().Model(e.entity)
 		q = addQueryLimit(q, e.restQuery.Limit)
 		q = addQueryOffset(q, e.restQuery.Offset)
-		q = addQueryFields(q, e.restQuery.Fields)
-		q = addQuerySorts(q, e.restQuery.Sorts)
-		q = addQueryFilter(q, e.restQuery.Filter, And)
+		if q, err = addQuerySelection(q, e.resource, e.restQuery.Fields); err != nil {
+			return NewErrorFromCause(err)
+		}
+		q = addQuerySelectedRelations(q, e.resource, e.restQuery.Fields, e.restQuery.Relations)
+		if q, err = addQuerySorts(q, e.resource, e.restQuery.Search, e.restQuery.Sorts); err != nil {
+			return NewErrorFromCause(err)
+		}
+		if q, err = e.addQueryConditions(q); err != nil {
+			return NewErrorFromCause(err)
+		}
+		if err = e.runSelectQueryHooks(ctx, q); err != nil {
+			return NewErrorFromCause(err)
+		}
 		e.count, err = q.ScanAndCount(ctx)
 		if err != nil {
 			return NewErrorFromCause(err)
@@ -71,6 +170,9 @@ func (e *Executor) GetSliceExecFunc() ExecFunc {
 func (e *Executor) InsertExecFunc() ExecFunc {
 	return func(ctx context.Context, tx *bun.Tx) error {
 		q := tx.NewInsert().Model(e.entity)
+		if err := e.runInsertQueryHooks(ctx, q); err != nil {
+			return NewErrorFromCause(err)
+		}
 		if _, err := q.Exec(ctx); err != nil {
 			return NewErrorFromCause(err)
 		}
@@ -83,22 +185,271 @@ func (e *Executor) InsertExecFunc() ExecFunc {
 func (e *Executor) UpdateExecFunc() ExecFunc {
 	return func(ctx context.Context, tx *bun.Tx) error {
 		q := tx.NewUpdate().Model(e.entity).WherePK()
-		if _, err := q.Exec(ctx); err != nil {
+		if len(e.columns) > 0 {
+			q = q.Column(e.columns...)
+		}
+		if err := e.runUpdateQueryHooks(ctx, q); err != nil {
+			return NewErrorFromCause(err)
+		}
+		res, err := q.Exec(ctx)
+		if err != nil {
 			return NewErrorFromCause(err)
 		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			// some drivers report changed rows only, check existence before returning not found
+			exists, err := tx.NewSelect().Model(e.entity).WherePK().Exists(ctx)
+			if err != nil {
+				return NewErrorFromCause(err)
+			}
+			if !exists {
+				return e.notFoundError()
+			}
+		}
+		e.count = 1
+		return nil
+	}
+}
+
+// PatchExecFunc updates columns execution function, fresh entity is returned using RETURNING where supported
+func (e *Executor) PatchExecFunc() ExecFunc {
+	return func(ctx context.Context, tx *bun.Tx) error {
+		if e.columns != nil && len(e.columns) == 0 {
+			// nothing to update
+			return e.GetOneExecFunc()(ctx, tx)
+		}
+		q := tx.NewUpdate().Model(e.entity).WherePK()
+		if len(e.columns) > 0 {
+			q = q.Column(e.columns...)
+		}
+		returning := tx.Dialect().Features().Has(feature.Returning)
+		if returning {
+			q = q.Returning("*")
+		}
+		if err := e.runUpdateQueryHooks(ctx, q); err != nil {
+			return NewErrorFromCause(err)
+		}
+		res, err := q.Exec(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return e.notFoundError()
+		}
+		if err != nil {
+			return NewErrorFromCause(err)
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			if returning {
+				return e.notFoundError()
+			}
+			exists, err := tx.NewSelect().Model(e.entity).WherePK().Exists(ctx)
+			if err != nil {
+				return NewErrorFromCause(err)
+			}
+			if !exists {
+				return e.notFoundError()
+			}
+		}
+		if !returning {
+			if err = tx.NewSelect().Model(e.entity).WherePK().Scan(ctx); err != nil {
+				return NewErrorFromCause(err)
+			}
+		}
+		e.count = 1
+		return nil
+	}
+}
+
+// UpsertExecFunc creates or replaces execution function, created is reported by the statement itself:
+// xmax on PostgreSQL, affected rows on MySQL, conflict ignoring insert elsewhere
+//
+// Insert query hooks run for each insert statement, update query hooks only for the update statement
+// of dialects without upsert support
+func (e *Executor) UpsertExecFunc() ExecFunc {
+	return func(ctx context.Context, tx *bun.Tx) error {
+		features := tx.Dialect().Features()
+		table := tx.Dialect().Tables().Get(reflect.TypeOf(e.entity))
+		var err error
+		switch {
+		case len(table.DataFields) > 0 && tx.Dialect().Name() == dialect.PG:
+			e.created, err = e.upsertReturning(ctx, tx, table)
+		case len(table.DataFields) > 0 && features.Has(feature.InsertOnDuplicateKey):
+			e.created, err = e.upsertDuplicateKey(ctx, tx)
+		case features.Has(feature.InsertOnConflict) || features.Has(feature.InsertIgnore):
+			e.created, err = e.upsertIgnoreFirst(ctx, tx, table)
+		default:
+			e.created, err = e.upsertUpdateFirst(ctx, tx)
+		}
+		if err != nil {
+			return err
+		}
 		e.count = 1
 		return nil
 	}
 }
 
+// conflictTarget returns ON CONFLICT clause of table pks with action
+func conflictTarget(table *schema.Table, action string) string {
+	pks := make([]string, len(table.PKs))
+	for i, pk := range table.PKs {
+		pks[i] = string(pk.SQLName)
+	}
+	return "CONFLICT (" + strings.Join(pks, ", ") + ") " + action
+}
+
+// upsertReturning upserts with single statement, xmax of inserted row is 0
+func (e *Executor) upsertReturning(ctx context.Context, tx *bun.Tx, table *schema.Table) (bool, error) {
+	q := tx.NewInsert().Model(e.entity).On(conflictTarget(table, "DO UPDATE")).Returning("(xmax = 0)")
+	if err := e.runInsertQueryHooks(ctx, q); err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	var created bool
+	if _, err := q.Exec(ctx, &created); err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	return created, nil
+}
+
+// upsertDuplicateKey upserts with single statement, MySQL reports 1 affected row for insert, 2 for update and 0 if unchanged
+func (e *Executor) upsertDuplicateKey(ctx context.Context, tx *bun.Tx) (bool, error) {
+	q := tx.NewInsert().Model(e.entity).On("DUPLICATE KEY UPDATE")
+	if err := e.runInsertQueryHooks(ctx, q); err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	return affected == 1, nil
+}
+
+// upsertIgnoreFirst inserts ignoring conflict, then replaces existing row if nothing has been inserted
+func (e *Executor) upsertIgnoreFirst(ctx context.Context, tx *bun.Tx, table *schema.Table) (bool, error) {
+	q := tx.NewInsert().Model(e.entity).Ignore()
+	if err := e.runInsertQueryHooks(ctx, q); err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	if affected > 0 || len(table.DataFields) == 0 {
+		// inserted, or nothing to replace when all columns are pk
+		return affected > 0, nil
+	}
+	q = tx.NewInsert().Model(e.entity).On(conflictTarget(table, "DO UPDATE"))
+	if err = e.runInsertQueryHooks(ctx, q); err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	if _, err = q.Exec(ctx); err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	return false, nil
+}
+
+// upsertUpdateFirst updates, then inserts if no row has been updated
+func (e *Executor) upsertUpdateFirst(ctx context.Context, tx *bun.Tx) (bool, error) {
+	q := tx.NewUpdate().Model(e.entity).WherePK()
+	if err := e.runUpdateQueryHooks(ctx, q); err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return false, NewErrorFromCause(err)
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+		return false, nil
+	}
+	if err = e.InsertExecFunc()(ctx, tx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // DeleteExecFunc deletes execution function
 func (e *Executor) DeleteExecFunc() ExecFunc {
 	return func(ctx context.Context, tx *bun.Tx) error {
 		q := tx.NewDelete().Model(e.entity).WherePK()
-		if _, err := q.Exec(ctx); err != nil {
+		if err := e.runDeleteQueryHooks(ctx, q); err != nil {
+			return NewErrorFromCause(err)
+		}
+		res, err := q.Exec(ctx)
+		if err != nil {
 			return NewErrorFromCause(err)
 		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return e.notFoundError()
+		}
 		e.count = 1
 		return nil
 	}
 }
+
+// addQueryConditions adds rest query filter, bounding box, search and sub-resource scope to select query
+func (e *Executor) addQueryConditions(q *bun.SelectQuery) (*bun.SelectQuery, error) {
+	q, err := addQueryFilter(q, e.config, e.resource, e.restQuery.Filter, And)
+	if err != nil {
+		return nil, err
+	}
+	if q, err = addQueryBbox(q, e.resource, e.restQuery.Bbox); err != nil {
+		return nil, err
+	}
+	if q, err = addQuerySearch(q, e.resource, e.restQuery.Search); err != nil {
+		return nil, err
+	}
+	return e.restQuery.scope.applyWhere(q), nil
+}
+
+// notFoundError returns not found error for rest query key
+func (e *Executor) notFoundError() error {
+	return NewErrorNotFound(fmt.Sprintf("resource '%v' with key '%v' not found", e.restQuery.Resource, e.restQuery.Key))
+}
+
+// runSelectQueryHooks runs global then resource select query hooks
+func (e *Executor) runSelectQueryHooks(ctx context.Context, q *bun.SelectQuery) error {
+	if err := e.config.queryHooks.runSelect(ctx, e.restQuery, q); err != nil {
+		return err
+	}
+	if e.resource == nil {
+		return nil
+	}
+	return e.resource.queryHooks.runSelect(ctx, e.restQuery, q)
+}
+
+// runInsertQueryHooks runs global then resource insert query hooks
+func (e *Executor) runInsertQueryHooks(ctx context.Context, q *bun.InsertQuery) error {
+	if err := e.config.queryHooks.runInsert(ctx, e.restQuery, q); err != nil {
+		return err
+	}
+	if e.resource == nil {
+		return nil
+	}
+	return e.resource.queryHooks.runInsert(ctx, e.restQuery, q)
+}
+
+// runUpdateQueryHooks runs global then resource update query hooks
+func (e *Executor) runUpdateQueryHooks(ctx context.Context, q *bun.UpdateQuery) error {
+	if err := e.config.queryHooks.runUpdate(ctx, e.restQuery, q); err != nil {
+		return err
+	}
+	if e.resource == nil {
+		return nil
+	}
+	return e.resource.queryHooks.runUpdate(ctx, e.restQuery, q)
+}
+
+// runDeleteQueryHooks runs global then resource delete query hooks
+func (e *Executor) runDeleteQueryHooks(ctx context.Context, q *bun.DeleteQuery) error {
+	if err := e.config.queryHooks.runDelete(ctx, e.restQuery, q); err != nil {
+		return err
+	}
+	if e.resource == nil {
+		return nil
+	}
+	return e.resource.queryHooks.runDelete(ctx, e.restQuery, q)
+}
@@ -0,0 +1,55 @@
+package brest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+)
+
+// FacetValue structure, distinct value of a field with its count
+type FacetValue struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// FacetsExecFunc computes facets execution function, top distinct values of requested fields
+// with their counts are computed with rest query filter
+func (e *Executor) FacetsExecFunc() ExecFunc {
+	return func(ctx context.Context, tx *bun.Tx) error {
+		table := tx.Dialect().Tables().Get(e.resource.ResourceType())
+		facets := make(map[string][]*FacetValue)
+		for _, facet := range e.restQuery.Facets {
+			field := tableField(table, facet.Name)
+			if field == nil {
+				return NewErrorBadRequest(fmt.Sprintf("unknown facet field '%v' for resource '%v'", facet.Name, e.resource.Name()))
+			}
+			q := tx.NewSelect().Model(reflect.New(e.resource.ResourceType()).Interface())
+			q = q.ColumnExpr("?TableAlias.? AS value", bun.Ident(field.Name))
+			q = q.ColumnExpr("count(*) AS count")
+			q = q.GroupExpr("?TableAlias.?", bun.Ident(field.Name))
+			q = q.OrderExpr("count DESC, value ASC")
+			q, err := e.addQueryConditions(q)
+			if err != nil {
+				return NewErrorFromCause(err)
+			}
+			if err = e.runSelectQueryHooks(ctx, q); err != nil {
+				return NewErrorFromCause(err)
+			}
+			q = addQueryLimit(q, e.restQuery.FacetLimit)
+			rows := make([]map[string]interface{}, 0)
+			if err := q.Scan(ctx, &rows); err != nil {
+				return NewErrorFromCause(err)
+			}
+			values := make([]*FacetValue, len(rows))
+			for i, row := range rows {
+				count, _ := row["count"].(int64)
+				values[i] = &FacetValue{Value: row["value"], Count: int(count)}
+			}
+			facets[field.GoName] = values
+		}
+		e.facets = facets
+		return nil
+	}
+}
@@ -0,0 +1,95 @@
+package brest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFacets(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	for _, book := range books {
+		_, err = db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 2, Facets: []*brest.Field{{Name: "AuthorID"}}, FacetLimit: 2})
+	assert.Nil(t, err)
+	page := res.(*brest.Page)
+	assert.Equal(t, 12, page.Count)
+	assert.Equal(t, 2, len(*page.Slice.(*[]Book)))
+	facet := page.Facets["AuthorID"]
+	assert.Equal(t, 2, len(facet))
+	assert.EqualValues(t, 1, facet[0].Value)
+	assert.Equal(t, 6, facet[0].Count)
+	assert.EqualValues(t, 2, facet[1].Value)
+	assert.Equal(t, 5, facet[1].Count)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 2, Facets: []*brest.Field{{Name: "author_id"}}, FacetLimit: 10, Filter: &brest.Filter{Op: brest.Lk, Attr: "title", Value: "L%"}})
+	assert.Nil(t, err)
+	facet = res.(*brest.Page).Facets["AuthorID"]
+	assert.Equal(t, 2, len(facet))
+	assert.EqualValues(t, 2, facet[0].Value)
+	assert.Equal(t, 5, facet[0].Count)
+	assert.Equal(t, 2, facet[1].Count)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Facets: []*brest.Field{{Name: "unknown"}}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+}
+
+func TestServerFacets(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var err error
+	var req *http.Request
+	var body []byte
+	var res *http.Response
+
+	for _, book := range books {
+		_, err = db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/Book?facets=AuthorID&facetLimit=1&filter="+url.QueryEscape(`{"Op":"neq","Attr":"author_id","Value":1}`), bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	page := &struct {
+		Count  int `json:"count"`
+		Facets map[string][]struct {
+			Value int `json:"value"`
+			Count int `json:"count"`
+		} `json:"facets"`
+	}{}
+	err = json.Unmarshal(body, page)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, page.Count)
+	assert.Equal(t, 1, len(page.Facets["AuthorID"]))
+	assert.Equal(t, 2, page.Facets["AuthorID"][0].Value)
+	assert.Equal(t, 5, page.Facets["AuthorID"][0].Count)
+}
@@ -0,0 +1,400 @@
+package brest
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// isAllFields returns true if fields don't restrict columns (empty or containing "*")
+func isAllFields(fields []*Field) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, field := range fields {
+		if field.Name == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// tableRelation returns relation of table by Go name (case insensitive)
+func tableRelation(table *schema.Table, name string) *schema.Relation {
+	for relName, rel := range table.Relations {
+		if strings.EqualFold(relName, name) {
+			return rel
+		}
+	}
+	return nil
+}
+
+// selectedColumns returns column fields of fields, dotted relation fields and relations are excluded,
+// pks are added to restricted columns for relations loading
+func selectedColumns(table *schema.Table, fields []*Field) []*Field {
+	if isAllFields(fields) {
+		return nil
+	}
+	columns := make([]*Field, 0, len(fields))
+	for _, field := range fields {
+		if strings.Contains(field.Name, ".") || tableRelation(table, field.Name) != nil {
+			continue
+		}
+		if tf := tableField(table, field.Name); tf != nil {
+			columns = append(columns, &Field{tf.Name})
+		} else if embedded := embeddedColumns(table, field.Name); len(embedded) > 0 {
+			columns = append(columns, embedded...)
+		} else {
+			columns = append(columns, field)
+		}
+	}
+	if len(columns) == 0 {
+		return columns
+	}
+	for _, pk := range table.PKs {
+		if !hasField(columns, pk.GoName, pk.Name) {
+			columns = append(columns, &Field{pk.Name})
+		}
+	}
+	return columns
+}
+
+// embeddedColumns returns columns of embedded struct of table by Go name (case insensitive)
+func embeddedColumns(table *schema.Table, name string) []*Field {
+	columns := make([]*Field, 0)
+	for i := 0; i < table.Type.NumField(); i++ {
+		if sf := table.Type.Field(i); !sf.Anonymous || !strings.EqualFold(sf.Name, name) {
+			continue
+		}
+		for _, field := range table.Fields {
+			if len(field.Index) > 1 && field.Index[0] == i {
+				columns = append(columns, &Field{field.Name})
+			}
+		}
+	}
+	return columns
+}
+
+// selectedRelations returns relations and relations of fields (relation names and dotted relation fields)
+func selectedRelations(table *schema.Table, fields []*Field, relations []*Relation) []*Relation {
+	selected := make([]*Relation, 0, len(relations))
+	names := make(map[string]bool)
+	add := func(name string) {
+		if !names[strings.ToLower(name)] {
+			names[strings.ToLower(name)] = true
+			selected = append(selected, &Relation{name})
+		}
+	}
+	for _, relation := range relations {
+		add(relation.Name)
+	}
+	for _, field := range fields {
+		parts := strings.Split(field.Name, ".")
+		t := table
+		path := make([]string, 0, len(parts))
+		for _, part := range parts {
+			if t == nil {
+				break
+			}
+			rel := tableRelation(t, part)
+			if rel == nil {
+				break
+			}
+			path = append(path, rel.Field.GoName)
+			t = rel.JoinTable
+		}
+		if len(path) > 0 {
+			add(strings.Join(path, "."))
+		}
+	}
+	return selected
+}
+
+// addQuerySelectedRelations adds relations and relations of fields to select query
+func addQuerySelectedRelations(query *bun.SelectQuery, resource *Resource, fields []*Field, relations []*Relation) *bun.SelectQuery {
+	if resource == nil {
+		return addQueryRelations(query, relations)
+	}
+	return addQueryRelations(query, selectedRelations(query.DB().Table(resource.ResourceType()), fields, relations))
+}
+
+// fieldTree selected struct fields by index, nil for whole field
+type fieldTree map[int]fieldTree
+
+// structType returns struct type of type, dereferencing pointers and slices
+func structType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// structField returns index path of exported field of model struct by Go, column or JSON name,
+// fields promoted from embedded structs are found through them, nil if not found
+func structField(db *bun.DB, t reflect.Type, name string) []int {
+	table := db.Table(t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if strings.EqualFold(sf.Name, name) {
+			return []int{i}
+		}
+		if sf.Anonymous {
+			continue
+		}
+		if jsonTag := strings.Split(sf.Tag.Get("json"), ",")[0]; jsonTag != "" && jsonTag != "-" && jsonTag == name {
+			return []int{i}
+		}
+		if field, ok := table.FieldMap[name]; ok && field.GoName == sf.Name {
+			return []int{i}
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" || !sf.Anonymous || structType(sf.Type) == nil {
+			continue
+		}
+		if index := structField(db, structType(sf.Type), name); index != nil {
+			return append([]int{i}, index...)
+		}
+	}
+	return nil
+}
+
+// embeddedNode returns node of tree holding fields of embedded structs of index path, nil if an embedded struct is whole
+func embeddedNode(node fieldTree, indexes []int) fieldTree {
+	for _, index := range indexes {
+		child, ok := node[index]
+		if ok && child == nil {
+			return nil
+		}
+		if child == nil {
+			child = make(fieldTree)
+			node[index] = child
+		}
+		node = child
+	}
+	return node
+}
+
+// newFieldTree builds tree of fields and relations paths for struct type, nil if fields don't restrict output
+func newFieldTree(db *bun.DB, t reflect.Type, fields []*Field, relations []*Relation) fieldTree {
+	t = structType(t)
+	if t == nil || isAllFields(fields) {
+		return nil
+	}
+	tree := make(fieldTree)
+	paths := make([][]string, 0, len(fields)+len(relations))
+	for _, field := range fields {
+		paths = append(paths, strings.Split(field.Name, "."))
+	}
+	for _, relation := range relations {
+		paths = append(paths, strings.Split(relation.Name, "."))
+	}
+	for i, path := range paths {
+		node := tree
+		st := t
+		whole := i >= len(fields)
+		for j, name := range path {
+			if name == "*" {
+				// all fields of struct
+				for k := 0; k < st.NumField(); k++ {
+					if sf := st.Field(k); sf.PkgPath == "" {
+						if _, ok := node[k]; !ok {
+							node[k] = nil
+						}
+					}
+				}
+				break
+			}
+			indexes := structField(db, st, name)
+			if indexes == nil {
+				break
+			}
+			parent := embeddedNode(node, indexes[:len(indexes)-1])
+			if parent == nil {
+				// whole embedded struct already selected
+				break
+			}
+			index := indexes[len(indexes)-1]
+			sf := st.FieldByIndex(indexes)
+			if j == len(path)-1 {
+				// explicit field selects whole field, relation keeps fields selected for it
+				if _, ok := parent[index]; !ok || !whole {
+					parent[index] = nil
+				}
+				break
+			}
+			if tableRelation(db.Table(st), sf.Name) == nil {
+				break
+			}
+			child, ok := parent[index]
+			if ok && child == nil {
+				// whole field already selected
+				break
+			}
+			if child == nil {
+				child = make(fieldTree)
+				parent[index] = child
+			}
+			st = structType(sf.Type)
+			node = child
+		}
+	}
+	return tree
+}
+
+// customEncoded returns true if values of t are encoded by their own methods, such types aren't projected
+func customEncoded(t reflect.Type) bool {
+	for _, i := range []reflect.Type{jsonMarshalerType, textMarshalerType, msgpackMarshalerType, msgpackEncoderType} {
+		if t.Implements(i) || reflect.PtrTo(t).Implements(i) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	jsonMarshalerType    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType    = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	msgpackMarshalerType = reflect.TypeOf((*msgpack.Marshaler)(nil)).Elem()
+	msgpackEncoderType   = reflect.TypeOf((*msgpack.CustomEncoder)(nil)).Elem()
+)
+
+// projectType returns type of t restricted to fields of tree, t is returned if it is encoded by its own methods
+// or embeds a type with methods (reflect can't build such struct types)
+func projectType(t reflect.Type, tree fieldTree) reflect.Type {
+	if tree == nil {
+		return t
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return reflect.PtrTo(projectType(t.Elem(), tree))
+	case reflect.Slice:
+		return reflect.SliceOf(projectType(t.Elem(), tree))
+	case reflect.Struct:
+		if customEncoded(t) {
+			return t
+		}
+		fields := make([]reflect.StructField, 0, len(tree))
+		for _, index := range tree.indexes() {
+			sf := t.Field(index)
+			ft := projectType(sf.Type, tree[index])
+			if sf.Anonymous && (ft.NumMethod() > 0 || reflect.PtrTo(ft).NumMethod() > 0) {
+				return t
+			}
+			fields = append(fields, reflect.StructField{Name: sf.Name, Type: ft, Tag: sf.Tag, Anonymous: sf.Anonymous})
+		}
+		return reflect.StructOf(fields)
+	}
+	return t
+}
+
+// indexes returns sorted struct field indexes of tree
+func (tree fieldTree) indexes() []int {
+	indexes := make([]int, 0, len(tree))
+	for index := range tree {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// projectValue copies fields of tree from v into value of projected type t
+func projectValue(v reflect.Value, t reflect.Type, tree fieldTree) reflect.Value {
+	if tree == nil || v.Type() == t {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(projectValue(v.Elem(), t.Elem(), tree))
+		return p
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		s := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(projectValue(v.Index(i), t.Elem(), tree))
+		}
+		return s
+	case reflect.Struct:
+		s := reflect.New(t).Elem()
+		for i, index := range tree.indexes() {
+			s.Field(i).Set(projectValue(v.Field(index), t.Field(i).Type, tree[index]))
+		}
+		return s
+	}
+	return v
+}
+
+// projectFields returns entity (pointer to resource struct or page of resource structs) restricted to fields
+// and relations of rest query, other results and entity not restricted by fields are returned unchanged
+func projectFields(config *Config, restQuery *RestQuery, entity interface{}) interface{} {
+	if isAllFields(restQuery.Fields) || entity == nil {
+		return entity
+	}
+	if page, ok := entity.(*Page); ok {
+		v := reflect.ValueOf(page.Slice)
+		if !isResourceType(config, v.Type()) {
+			return entity
+		}
+		tree := newFieldTree(config.DB(), v.Type(), restQuery.Fields, restQuery.Relations)
+		if tree == nil {
+			return entity
+		}
+		projected := *page
+		projected.Slice = projectValue(v, projectType(v.Type(), tree), tree).Interface()
+		return &projected
+	}
+	v := reflect.ValueOf(entity)
+	if v.Kind() != reflect.Ptr || !isResourceType(config, v.Type()) {
+		return entity
+	}
+	tree := newFieldTree(config.DB(), v.Type(), restQuery.Fields, restQuery.Relations)
+	if tree == nil {
+		return entity
+	}
+	return projectValue(v, projectType(v.Type(), tree), tree).Interface()
+}
+
+// isResourceType returns true if t, after dereferencing pointers and slices, is type of a (not virtual) resource
+func isResourceType(config *Config, t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && config.getResourceByType(t) != nil
+}
+
+// selectedProperties returns JSON names of fields of resource type, nil if fields don't restrict output
+func selectedProperties(db *bun.DB, resourceType reflect.Type, fields []*Field) map[string]bool {
+	if isAllFields(fields) {
+		return nil
+	}
+	properties := make(map[string]bool)
+	table := db.Table(resourceType)
+	for _, f := range fields {
+		name := strings.Split(f.Name, ".")[0]
+		if field := tableField(table, name); field != nil {
+			properties[jsonName(field)] = true
+		} else if rel := tableRelation(table, name); rel != nil {
+			properties[jsonName(rel.Field)] = true
+		}
+	}
+	return properties
+}
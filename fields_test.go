@@ -0,0 +1,162 @@
+package brest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Stamp struct {
+	Created time.Time
+	Creator string
+}
+
+type ZNote struct {
+	ID   int `bun:",pk,autoincrement"`
+	Text string
+	Stamp
+}
+
+type Secret struct {
+	ID    int `bun:",pk,autoincrement"`
+	Value string
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"id": s.ID, "masked": true})
+}
+
+func getBody(t *testing.T, ts *httptest.Server, uri string, accept string) []byte {
+	req, err := http.NewRequest("GET", ts.URL+uri, bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	req.Header.Set("Accept", accept)
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	return body
+}
+
+func TestServerSparseFields(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	for _, author := range authors {
+		_, err := db.NewInsert().Model(&author).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+	for _, book := range books {
+		_, err := db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	body := getBody(t, ts, "/rest/Book/1?fields=Title,Author.Lastname", brest.Json)
+	assert.JSONEq(t, `{"Title":"Courrier sud","Author":{"Lastname":"de Saint Exupéry"}}`, string(body))
+
+	body = getBody(t, ts, "/rest/Book/1?fields=Title,Author.Lastname", brest.Msgpack)
+	book := make(map[string]interface{})
+	err := msgpack.Unmarshal(body, &book)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(book))
+	assert.Equal(t, "Courrier sud", book["Title"])
+	assert.Equal(t, map[string]interface{}{"Lastname": "de Saint Exupéry"}, book["Author"])
+
+	body = getBody(t, ts, "/rest/Author?fields=Lastname,Books.Title&sort=ID&limit=1", brest.Json)
+	page := make(map[string]interface{})
+	err = json.Unmarshal(body, &page)
+	assert.Nil(t, err)
+	author := page["slice"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 2, len(author))
+	assert.Equal(t, "de Saint Exupéry", author["Lastname"])
+	assert.Equal(t, 6, len(author["Books"].([]interface{})))
+	assert.Equal(t, map[string]interface{}{"Title": "Courrier sud"}, author["Books"].([]interface{})[0])
+
+	body = getBody(t, ts, "/rest/Book/1?fields=Title&relations=Author", brest.Json)
+	book = make(map[string]interface{})
+	err = json.Unmarshal(body, &book)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(book))
+	assert.Equal(t, "Antoine", book["Author"].(map[string]interface{})["Firstname"])
+
+	body = getBody(t, ts, "/rest/Book/1?fields=*", brest.Json)
+	book = make(map[string]interface{})
+	err = json.Unmarshal(body, &book)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), book["ID"])
+	assert.Equal(t, "Courrier sud", book["Title"])
+
+	// results other than entities aren't projected
+	body = getBody(t, ts, "/rest/Book?aggregate=count(*)&groupBy=AuthorID&fields=Title", brest.Json)
+	aggregate := make(map[string]interface{})
+	err = json.Unmarshal(body, &aggregate)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(aggregate["rows"].([]interface{})))
+
+	req, err := http.NewRequest("POST", ts.URL+"/rest/Book?fields=Title", bytes.NewBufferString(`[{"Title":"Vol de nuit"},{"Title":"Terre des hommes"}]`))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", brest.Json)
+	req.Header.Set("Accept", brest.Json)
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	result := make(map[string]interface{})
+	err = json.Unmarshal(body, &result)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, result["succeeded"])
+	assert.Equal(t, "Vol de nuit", result["items"].([]interface{})[0].(map[string]interface{})["entity"].(map[string]interface{})["Title"])
+}
+
+func TestServerSparseFieldsEmbedded(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.AddResource(brest.NewResource("ZNote", (*ZNote)(nil), brest.All))
+	config.AddResource(brest.NewResource("Secret", (*Secret)(nil), brest.All))
+	db.ResetModel(context.Background(), (*ZNote)(nil), (*Secret)(nil))
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	_, err := db.NewInsert().Model(&ZNote{Text: "hello", Stamp: Stamp{Created: created, Creator: "joe"}}).Exec(context.Background())
+	assert.Nil(t, err)
+	_, err = db.NewInsert().Model(&Secret{Value: "password"}).Exec(context.Background())
+	assert.Nil(t, err)
+
+	// promoted fields of embedded struct
+	body := getBody(t, ts, "/rest/ZNote/1?fields=Text,Created", brest.Json)
+	assert.JSONEq(t, `{"Text":"hello","Created":"2024-03-01T12:00:00Z"}`, string(body))
+
+	body = getBody(t, ts, "/rest/ZNote/1?fields=Text,Stamp", brest.Json)
+	assert.JSONEq(t, `{"Text":"hello","Created":"2024-03-01T12:00:00Z","Creator":"joe"}`, string(body))
+
+	body = getBody(t, ts, "/rest/ZNote/1?fields=Creator", brest.Msgpack)
+	note := make(map[string]interface{})
+	err = msgpack.Unmarshal(body, &note)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"Creator": "joe"}, note)
+
+	// custom marshaler isn't bypassed
+	body = getBody(t, ts, "/rest/Secret/1?fields=ID", brest.Json)
+	assert.JSONEq(t, `{"id":1,"masked":true}`, string(body))
+}
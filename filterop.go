@@ -0,0 +1,368 @@
+package brest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// opRenderer renders condition with its arguments of filter operation for dialect
+type opRenderer func(name dialect.Name, filter *Filter) (string, []interface{}, error)
+
+// opArgs builds arguments of filter operation for dialect
+type opArgs func(name dialect.Name, filter *Filter) ([]interface{}, error)
+
+// anyDialect key of template used for dialects without specific template
+const anyDialect = dialect.Invalid
+
+// unsupportedError returns error of operation not supported by dialect
+func unsupportedError(name dialect.Name, op Op) error {
+	return NewErrorBadRequest(fmt.Sprintf("operator '%v' not supported for dialect '%v'", op, name))
+}
+
+// templateRenderer renders condition template of dialect, attribute and value are arguments if args is nil
+func templateRenderer(templates map[dialect.Name]string, args opArgs) opRenderer {
+	return func(name dialect.Name, filter *Filter) (string, []interface{}, error) {
+		template, ok := templates[name]
+		if !ok {
+			if template, ok = templates[anyDialect]; !ok {
+				return "", nil, unsupportedError(name, filter.Op)
+			}
+		}
+		if args == nil {
+			return template, []interface{}{filterAttr(filter), filter.Value}, nil
+		}
+		values, err := args(name, filter)
+		return template, values, err
+	}
+}
+
+// filterAttr returns identifier of filter attribute or SQL expression of computed attribute
+func filterAttr(filter *Filter) interface{} {
+	if filter.expr != "" {
+		return schema.SafeQuery("("+filter.expr+")", nil)
+	}
+	return schema.Ident(filter.Attr)
+}
+
+// attrArgs returns attribute only
+func attrArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	return []interface{}{filterAttr(filter)}, nil
+}
+
+// inArgs returns attribute and value list
+func inArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	return []interface{}{filterAttr(filter), bun.In(filter.Value)}, nil
+}
+
+// rangeArgs returns attribute and the 2 values of range
+func rangeArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	values, err := filterValues(filter, 2)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{filterAttr(filter), values[0], values[1]}, nil
+}
+
+// likeArgs returns attribute and escaped value with prefix and suffix
+func likeArgs(prefix string, suffix string) opArgs {
+	return func(name dialect.Name, filter *Filter) ([]interface{}, error) {
+		return []interface{}{filterAttr(filter), prefix + likeEscape(filter.Value) + suffix}, nil
+	}
+}
+
+// similarArgs returns attribute and SIMILAR TO pattern, converted to regular expression except for Postgres
+func similarArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	if name == dialect.PG {
+		return []interface{}{filterAttr(filter), filter.Value}, nil
+	}
+	return []interface{}{filterAttr(filter), similarToRegexp(fmt.Sprint(filter.Value))}, nil
+}
+
+// arrayArgs returns attribute and Postgres array literal
+func arrayArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	return []interface{}{filterAttr(filter), arrayLiteral(filter.Value)}, nil
+}
+
+// jsonPathArgs returns attribute and JSON path
+func jsonPathArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	return []interface{}{filterAttr(filter), jsonPath(filter.Value)}, nil
+}
+
+// jsonEqArgs returns attribute, JSON path and JSON encoded value
+func jsonEqArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	values, err := filterValues(filter, 2)
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(values[1])
+	if err != nil {
+		return nil, NewErrorBadRequest(fmt.Sprintf("invalid value for operator '%v'", filter.Op))
+	}
+	return []interface{}{filterAttr(filter), jsonPath(values[0]), string(value)}, nil
+}
+
+// bboxArgs returns attribute, the 4 values of bounding box and attribute for SRID
+func bboxArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	values, err := filterValues(filter, 4)
+	if err != nil {
+		return nil, err
+	}
+	args := []interface{}{filterAttr(filter)}
+	for _, value := range values {
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, NewErrorBadRequest(fmt.Sprintf("invalid bounding box value '%v' for operator '%v'", value, filter.Op))
+		}
+		args = append(args, f)
+	}
+	return append(args, filterAttr(filter)), nil
+}
+
+// geometryArgs returns attribute, GeoJSON geometry and attribute for SRID
+func geometryArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	geometry, err := geoJSONValue(filter.Op, filter.Value)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{filterAttr(filter), geometry, filterAttr(filter)}, nil
+}
+
+// distanceArgs returns attribute, GeoJSON geometry, attribute for SRID and distance
+func distanceArgs(name dialect.Name, filter *Filter) ([]interface{}, error) {
+	values, err := filterValues(filter, 2)
+	if err != nil {
+		return nil, err
+	}
+	geometry, err := geoJSONValue(filter.Op, values[0])
+	if err != nil {
+		return nil, err
+	}
+	distance, err := toFloat(values[1])
+	if err != nil {
+		return nil, NewErrorBadRequest(fmt.Sprintf("invalid distance '%v' for operator '%v'", values[1], filter.Op))
+	}
+	return []interface{}{filterAttr(filter), geometry, filterAttr(filter), distance}, nil
+}
+
+// geoJSONValue returns GeoJSON text of geometry value (GeoJSON object or text)
+func geoJSONValue(op Op, value interface{}) (string, error) {
+	if text, ok := value.(string); ok {
+		value = json.RawMessage(text)
+	}
+	geometry := new(Geometry)
+	if data, err := json.Marshal(value); err == nil {
+		if err = json.Unmarshal(data, geometry); err == nil {
+			if _, err = geometry.Positions(); err == nil || geometry.Type == "GeometryCollection" {
+				data, err = json.Marshal(geometry)
+				return string(data), err
+			}
+		}
+	}
+	return "", NewErrorBadRequest(fmt.Sprintf("invalid GeoJSON geometry '%v' for operator '%v'", value, op))
+}
+
+// sqliteFunctionOps operations using unaccent or regexp SQL functions, unsupported on SQLite
+// unless these functions are registered
+var sqliteFunctionOps = map[Op]bool{Sim: true, Nsim: true, Lulk: true, Nlulk: true}
+
+// unaccentLike templates of case and accent insensitive LIKE, unaccent function must be registered on SQLite
+func unaccentLike(not string) map[dialect.Name]string {
+	return map[dialect.Name]string{
+		dialect.PG:     "lower(unaccent(?)) " + not + "LIKE lower(unaccent(?))",
+		dialect.SQLite: "lower(unaccent(?)) " + not + "LIKE lower(unaccent(?))",
+		dialect.MySQL:  "? " + not + "LIKE ? COLLATE utf8mb4_general_ci",
+	}
+}
+
+// regexpTemplates templates of SIMILAR TO, REGEXP is used except for Postgres,
+// regexp function must be registered on SQLite
+func regexpTemplates(not string) map[dialect.Name]string {
+	return map[dialect.Name]string{
+		dialect.PG:     "? " + not + "SIMILAR TO ?",
+		dialect.SQLite: "? " + not + "REGEXP ?",
+		dialect.MySQL:  "? " + not + "REGEXP ?",
+	}
+}
+
+// opRenderers renderers of filter operations, except groups and full-text search
+var opRenderers = map[Op]opRenderer{
+	Eq:            templateRenderer(map[dialect.Name]string{anyDialect: "? = ?"}, nil),
+	Neq:           templateRenderer(map[dialect.Name]string{anyDialect: "? != ?"}, nil),
+	In:            templateRenderer(map[dialect.Name]string{anyDialect: "? IN (?)"}, inArgs),
+	Nin:           templateRenderer(map[dialect.Name]string{anyDialect: "? NOT IN (?)"}, inArgs),
+	Gt:            templateRenderer(map[dialect.Name]string{anyDialect: "? > ?"}, nil),
+	Gte:           templateRenderer(map[dialect.Name]string{anyDialect: "? >= ?"}, nil),
+	Lt:            templateRenderer(map[dialect.Name]string{anyDialect: "? < ?"}, nil),
+	Lte:           templateRenderer(map[dialect.Name]string{anyDialect: "? <= ?"}, nil),
+	Lk:            templateRenderer(map[dialect.Name]string{anyDialect: "? LIKE ?"}, nil),
+	Nlk:           templateRenderer(map[dialect.Name]string{anyDialect: "? NOT LIKE ?"}, nil),
+	Llk:           templateRenderer(map[dialect.Name]string{anyDialect: "lower(?) LIKE lower(?)", dialect.MySQL: "? LIKE ? COLLATE utf8mb4_general_ci"}, nil),
+	Nllk:          templateRenderer(map[dialect.Name]string{anyDialect: "lower(?) NOT LIKE lower(?)", dialect.MySQL: "? NOT LIKE ? COLLATE utf8mb4_general_ci"}, nil),
+	Sim:           templateRenderer(regexpTemplates(""), similarArgs),
+	Nsim:          templateRenderer(regexpTemplates("NOT "), similarArgs),
+	Lulk:          templateRenderer(unaccentLike(""), nil),
+	Nlulk:         templateRenderer(unaccentLike("NOT "), nil),
+	Null:          templateRenderer(map[dialect.Name]string{anyDialect: "? IS NULL"}, attrArgs),
+	Nnull:         templateRenderer(map[dialect.Name]string{anyDialect: "? IS NOT NULL"}, attrArgs),
+	Between:       templateRenderer(map[dialect.Name]string{anyDialect: "? BETWEEN ? AND ?"}, rangeArgs),
+	Nbetween:      templateRenderer(map[dialect.Name]string{anyDialect: "? NOT BETWEEN ? AND ?"}, rangeArgs),
+	StartsWith:    templateRenderer(map[dialect.Name]string{anyDialect: "? LIKE ? ESCAPE '!'"}, likeArgs("", "%")),
+	EndsWith:      templateRenderer(map[dialect.Name]string{anyDialect: "? LIKE ? ESCAPE '!'"}, likeArgs("%", "")),
+	Contains:      templateRenderer(map[dialect.Name]string{anyDialect: "? LIKE ? ESCAPE '!'"}, likeArgs("%", "%")),
+	Ieq:           templateRenderer(map[dialect.Name]string{anyDialect: "lower(?) = lower(?)"}, nil),
+	ArrayContains: templateRenderer(map[dialect.Name]string{dialect.PG: "? @> ?"}, arrayArgs),
+	ArrayOverlap:  templateRenderer(map[dialect.Name]string{dialect.PG: "? && ?"}, arrayArgs),
+	JSONExists: templateRenderer(map[dialect.Name]string{
+		dialect.SQLite: "json_type(?, ?) IS NOT NULL",
+		dialect.PG:     "jsonb_path_exists(CAST(? AS jsonb), CAST(? AS jsonpath))",
+		dialect.MySQL:  "JSON_CONTAINS_PATH(?, 'one', ?)",
+	}, jsonPathArgs),
+	JSONEq: templateRenderer(map[dialect.Name]string{
+		dialect.SQLite: "json_extract(?, ?) IS json_extract(?, '$')",
+		dialect.PG:     "jsonb_path_query_first(CAST(? AS jsonb), CAST(? AS jsonpath)) = CAST(? AS jsonb)",
+		dialect.MySQL:  "JSON_EXTRACT(?, ?) = CAST(? AS JSON)",
+	}, jsonEqArgs),
+	DatePart: renderDatePart,
+	Intersects: templateRenderer(map[dialect.Name]string{
+		dialect.PG:     "? && ST_MakeEnvelope(?, ?, ?, ?, ST_SRID(?))",
+		dialect.SQLite: "MbrIntersects(?, BuildMbr(?, ?, ?, ?, SRID(?)))",
+		dialect.MySQL:  "MBRIntersects(?, ST_SRID(ST_MakeEnvelope(Point(?, ?), Point(?, ?)), ST_SRID(?)))",
+	}, bboxArgs),
+	Within: templateRenderer(map[dialect.Name]string{
+		dialect.PG:     "ST_Within(?, ST_SetSRID(ST_GeomFromGeoJSON(?), ST_SRID(?)))",
+		dialect.SQLite: "ST_Within(?, SetSRID(GeomFromGeoJSON(?), SRID(?)))",
+	}, geometryArgs),
+	DWithin: templateRenderer(map[dialect.Name]string{
+		dialect.PG:     "ST_DWithin(?, ST_SetSRID(ST_GeomFromGeoJSON(?), ST_SRID(?)), ?)",
+		dialect.SQLite: "ST_Distance(?, SetSRID(GeomFromGeoJSON(?), SRID(?))) <= ?",
+	}, distanceArgs),
+}
+
+// renderCondition renders condition with its arguments of filter operation for dialect,
+// sqliteFunctions is true if unaccent and regexp SQL functions are registered on SQLite
+func renderCondition(name dialect.Name, filter *Filter, sqliteFunctions bool) (string, []interface{}, error) {
+	renderer, ok := opRenderers[filter.Op]
+	if !ok {
+		return "", nil, NewErrorBadRequest(fmt.Sprintf("unknown operator '%v'", filter.Op))
+	}
+	if name == dialect.SQLite && sqliteFunctionOps[filter.Op] && !sqliteFunctions {
+		return "", nil, unsupportedError(name, filter.Op)
+	}
+	return renderer(name, filter)
+}
+
+// likeEscape escapes LIKE wildcards of value with '!'
+func likeEscape(value interface{}) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(fmt.Sprint(value))
+}
+
+// similarToRegexp converts SIMILAR TO pattern to anchored regular expression
+func similarToRegexp(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("^(")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		case r == '.' || r == '^' || r == '$':
+			sb.WriteString("\\" + string(r))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString(")$")
+	return sb.String()
+}
+
+// filterValues returns the n values of filter array value
+func filterValues(filter *Filter, n int) ([]interface{}, error) {
+	v := reflect.ValueOf(filter.Value)
+	if v.Kind() != reflect.Slice || v.Len() != n {
+		return nil, NewErrorBadRequest(fmt.Sprintf("operator '%v' needs an array of %v values", filter.Op, n))
+	}
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+	return values, nil
+}
+
+// arrayLiteral returns Postgres array literal of value ('{"a","b"}'), type is inferred from attribute
+func arrayLiteral(value interface{}) string {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice {
+		v = reflect.ValueOf([]interface{}{value})
+	}
+	items := make([]string, v.Len())
+	escaper := strings.NewReplacer("\\", "\\\\", "\"", "\\\"")
+	for i := range items {
+		items[i] = "\"" + escaper.Replace(fmt.Sprint(v.Index(i).Interface())) + "\""
+	}
+	return "{" + strings.Join(items, ",") + "}"
+}
+
+// jsonPath returns JSON path of value, "$." prefix is added if missing
+func jsonPath(value interface{}) string {
+	path := fmt.Sprint(value)
+	if !strings.HasPrefix(path, "$") {
+		path = "$." + path
+	}
+	return path
+}
+
+// datePartExprs maps date part to expression by dialect
+var datePartExprs = map[string]map[dialect.Name]string{
+	"year":   {dialect.SQLite: "CAST(strftime('%Y', ?) AS INTEGER)", dialect.PG: "CAST(EXTRACT(YEAR FROM ?) AS INTEGER)", dialect.MySQL: "YEAR(?)"},
+	"month":  {dialect.SQLite: "CAST(strftime('%m', ?) AS INTEGER)", dialect.PG: "CAST(EXTRACT(MONTH FROM ?) AS INTEGER)", dialect.MySQL: "MONTH(?)"},
+	"day":    {dialect.SQLite: "CAST(strftime('%d', ?) AS INTEGER)", dialect.PG: "CAST(EXTRACT(DAY FROM ?) AS INTEGER)", dialect.MySQL: "DAYOFMONTH(?)"},
+	"dow":    {dialect.SQLite: "CAST(strftime('%w', ?) AS INTEGER)", dialect.PG: "CAST(EXTRACT(DOW FROM ?) AS INTEGER)", dialect.MySQL: "(DAYOFWEEK(?) - 1)"},
+	"hour":   {dialect.SQLite: "CAST(strftime('%H', ?) AS INTEGER)", dialect.PG: "CAST(EXTRACT(HOUR FROM ?) AS INTEGER)", dialect.MySQL: "HOUR(?)"},
+	"minute": {dialect.SQLite: "CAST(strftime('%M', ?) AS INTEGER)", dialect.PG: "CAST(EXTRACT(MINUTE FROM ?) AS INTEGER)", dialect.MySQL: "MINUTE(?)"},
+}
+
+// datePartComparators maps comparison operation of date part to SQL
+var datePartComparators = map[Op]string{Eq: "=", Neq: "!=", Gt: ">", Gte: ">=", Lt: "<", Lte: "<="}
+
+// renderDatePart renders date part comparison
+func renderDatePart(name dialect.Name, filter *Filter) (string, []interface{}, error) {
+	values, err := filterValues(filter, 3)
+	if err != nil {
+		return "", nil, err
+	}
+	exprs, ok := datePartExprs[strings.ToLower(fmt.Sprint(values[0]))]
+	if !ok {
+		return "", nil, NewErrorBadRequest(fmt.Sprintf("unknown date part '%v'", values[0]))
+	}
+	comparator, ok := datePartComparators[Op(fmt.Sprint(values[1]))]
+	if !ok {
+		return "", nil, NewErrorBadRequest(fmt.Sprintf("unknown date part comparison '%v'", values[1]))
+	}
+	expr, ok := exprs[name]
+	if !ok {
+		return "", nil, unsupportedError(name, filter.Op)
+	}
+	return expr + " " + comparator + " ?", []interface{}{filterAttr(filter), values[2]}, nil
+}
+
+// addWhereCondition adds condition to query according to parent group operation
+func addWhereCondition(query *bun.SelectQuery, condition string, args []interface{}, parentGroupOp Op) *bun.SelectQuery {
+	if parentGroupOp == Or {
+		return query.WhereOr(condition, args...)
+	}
+	return query.Where(condition, args...)
+}
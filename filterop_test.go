@@ -0,0 +1,132 @@
+package brest_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/aptogeo/brest"
+	"github.com/aptogeo/brest/sqlitefunc"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+type Event struct {
+	ID   int `bun:",pk,autoincrement"`
+	Name string
+	Data map[string]interface{} `bun:"type:json"`
+	At   time.Time
+}
+
+var events = []Event{{
+	Name: "100% rock",
+	Data: map[string]interface{}{"kind": "concert", "seats": 100},
+	At:   time.Date(2023, 6, 21, 20, 0, 0, 0, time.UTC),
+}, {
+	Name: "Rock_Paper",
+	Data: map[string]interface{}{"kind": "game"},
+	At:   time.Date(2024, 1, 6, 14, 0, 0, 0, time.UTC),
+}, {
+	Name: "Jazz night",
+	Data: map[string]interface{}{"kind": "concert", "seats": 50, "outdoor": map[string]interface{}{"covered": true}},
+	At:   time.Date(2024, 7, 14, 21, 0, 0, 0, time.UTC),
+}}
+
+func TestExtendedFilterOps(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.AddResource(brest.NewResource("Event", (*Event)(nil), brest.All))
+	db.ResetModel(context.Background(), (*Event)(nil))
+	engine := brest.NewEngine(config)
+
+	var err error
+
+	for _, event := range events {
+		_, err = db.NewInsert().Model(&event).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	count := func(filter *brest.Filter) int {
+		res, err := engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Event", Limit: 10, Filter: filter})
+		assert.Nil(t, err)
+		if err != nil {
+			return -1
+		}
+		return res.(*brest.Page).Count
+	}
+
+	assert.Equal(t, 2, count(&brest.Filter{Op: brest.Between, Attr: "id", Value: []interface{}{2, 3}}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.Nbetween, Attr: "id", Value: []interface{}{2, 3}}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.StartsWith, Attr: "name", Value: "100%"}))
+	assert.Equal(t, 0, count(&brest.Filter{Op: brest.StartsWith, Attr: "name", Value: "1%"}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.Contains, Attr: "name", Value: "k_P"}))
+	assert.Equal(t, 0, count(&brest.Filter{Op: brest.Contains, Attr: "name", Value: "k_r"}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.EndsWith, Attr: "name", Value: "night"}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.Ieq, Attr: "name", Value: "JAZZ NIGHT"}))
+	assert.Equal(t, 2, count(&brest.Filter{Op: brest.JSONExists, Attr: "data", Value: "seats"}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.JSONExists, Attr: "data", Value: "$.outdoor.covered"}))
+	assert.Equal(t, 2, count(&brest.Filter{Op: brest.JSONEq, Attr: "data", Value: []interface{}{"kind", "concert"}}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.JSONEq, Attr: "data", Value: []interface{}{"seats", 50}}))
+	assert.Equal(t, 2, count(&brest.Filter{Op: brest.DatePart, Attr: "at", Value: []interface{}{"year", "eq", 2024}}))
+	assert.Equal(t, 2, count(&brest.Filter{Op: brest.DatePart, Attr: "at", Value: []interface{}{"hour", "gte", 20}}))
+	assert.Equal(t, 2, count(&brest.Filter{Op: brest.Or, Filters: []*brest.Filter{{Op: brest.DatePart, Attr: "at", Value: []interface{}{"dow", "eq", 6}}, {Op: brest.DatePart, Attr: "at", Value: []interface{}{"dow", "eq", 0}}}}))
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Event", Filter: &brest.Filter{Op: brest.ArrayContains, Attr: "name", Value: []string{"a"}}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Event", Filter: &brest.Filter{Op: brest.Between, Attr: "id", Value: 2}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Event", Filter: &brest.Filter{Op: "foo", Attr: "id", Value: 2}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+	assert.Contains(t, err.Error(), "unknown operator 'foo'")
+}
+
+func TestDialectFilterOps(t *testing.T) {
+	// operations using unaccent or regexp functions are unsupported unless functions are registered
+	db, config := initTests(t)
+	_, err := brest.NewEngine(config).Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Filter: &brest.Filter{Op: brest.Lulk, Attr: "title", Value: "%a%"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+	db.Close()
+
+	err = sqlitefunc.Register()
+	assert.Nil(t, err)
+	// functions are registered with the driver named "sqlite"
+	sqldb, err := sql.Open("sqlite", "file:dialect?mode=memory&cache=shared")
+	assert.Nil(t, err)
+	db = bun.NewDB(sqldb, sqlitedialect.New())
+	defer db.Close()
+	config = brest.NewConfig("/rest/", db)
+	config.SetSQLiteFunctions(true)
+	config.AddResource(brest.NewResource("Book", (*Book)(nil), brest.All))
+	db.ResetModel(context.Background(), (*Book)(nil))
+	engine := brest.NewEngine(config)
+
+	for _, book := range books {
+		_, err = db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	count := func(filter *brest.Filter) int {
+		res, err := engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 20, Filter: filter})
+		assert.Nil(t, err)
+		if err != nil {
+			return -1
+		}
+		return res.(*brest.Page).Count
+	}
+
+	assert.Equal(t, 5, count(&brest.Filter{Op: brest.Sim, Attr: "title", Value: "L(a|e) %"}))
+	assert.Equal(t, 7, count(&brest.Filter{Op: brest.Nsim, Attr: "title", Value: "L(a|e) %"}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.Sim, Attr: "title", Value: "Vol de nuit"}))
+	assert.Equal(t, 0, count(&brest.Filter{Op: brest.Sim, Attr: "title", Value: "Vol.de nuit"}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.Lulk, Attr: "title", Value: "%AMERIQUE%"}))
+	assert.Equal(t, 1, count(&brest.Filter{Op: brest.And, Filters: []*brest.Filter{{Op: brest.Lulk, Attr: "title", Value: "%chateau%"}, {Op: brest.Nlulk, Attr: "title", Value: "%metamorphose%"}}}))
+	assert.Equal(t, "Lettre a un otage, Chateau, OEuvre", sqlitefunc.Unaccent("Lettre à un otage, Château, Œuvre"))
+}
@@ -0,0 +1,190 @@
+package brest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// Feature GeoJSON feature of entity
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollection GeoJSON feature collection of page
+type FeatureCollection struct {
+	Type           string     `json:"type"`
+	Features       []*Feature `json:"features"`
+	NumberMatched  int        `json:"numberMatched"`
+	NumberReturned int        `json:"numberReturned"`
+}
+
+// geometryTableField returns schema field of resource geometry field
+func (r *Resource) geometryTableField(db *bun.DB) (*schema.Field, error) {
+	if r.geometryField == "" {
+		return nil, NewErrorBadRequest(fmt.Sprintf("resource '%v' has no geometry field", r.name))
+	}
+	field := tableField(db.Table(r.resourceType), r.geometryField)
+	if field == nil {
+		return nil, NewErrorBadRequest(fmt.Sprintf("unknown geometry field '%v' for resource '%v'", r.geometryField, r.name))
+	}
+	return field, nil
+}
+
+// addQueryBbox adds bounding box intersection of resource geometry field to query
+func addQueryBbox(query *bun.SelectQuery, resource *Resource, bbox []float64) (*bun.SelectQuery, error) {
+	if len(bbox) == 0 {
+		return query, nil
+	}
+	if resource == nil {
+		return nil, NewErrorBadRequest("bbox needs a resource with geometry field")
+	}
+	field, err := resource.geometryTableField(query.DB())
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(bbox))
+	for i, f := range bbox {
+		values[i] = f
+	}
+	condition, args, err := renderCondition(query.Dialect().Name(), &Filter{Op: Intersects, Attr: field.Name, Value: values}, false)
+	if err != nil {
+		return nil, err
+	}
+	return query.Where(condition, args...), nil
+}
+
+// entityGeometry returns geometry of geometry field of elem, nil if empty
+func entityGeometry(field *schema.Field, elem reflect.Value) *Geometry {
+	switch g := field.Value(elem).Interface().(type) {
+	case *Geometry:
+		return g
+	case Geometry:
+		if g.Type == "" {
+			return nil
+		}
+		return &g
+	}
+	return nil
+}
+
+// feature returns GeoJSON feature of elem, properties are JSON fields except geometry
+func (r *Resource) feature(db *bun.DB, field *schema.Field, elem reflect.Value) (*Feature, error) {
+	elem = reflect.Indirect(elem)
+	f := &Feature{Type: "Feature", Geometry: entityGeometry(field, elem)}
+	table := db.Table(r.resourceType)
+	if len(table.PKs) == 1 {
+		if pk := table.PKs[0].Value(elem); !pk.IsZero() {
+			f.ID = pk.Interface()
+		}
+	} else if key, err := getPk(db, r.resourceType, elem); err == nil {
+		f.ID = key
+	}
+	data, err := json.Marshal(elem.Interface())
+	if err != nil {
+		return nil, err
+	}
+	if err = decodeJSON(data, &f.Properties); err != nil {
+		return nil, err
+	}
+	delete(f.Properties, jsonName(field))
+	return f, nil
+}
+
+// selectedFeature returns GeoJSON feature of elem with properties restricted to given JSON names if not nil
+func (r *Resource) selectedFeature(db *bun.DB, field *schema.Field, elem reflect.Value, properties map[string]bool) (*Feature, error) {
+	f, err := r.feature(db, field, elem)
+	if err != nil || properties == nil {
+		return f, err
+	}
+	for name := range f.Properties {
+		if !properties[name] {
+			delete(f.Properties, name)
+		}
+	}
+	return f, nil
+}
+
+// geoJSON converts entity (pointer to resource struct) into Feature or page into FeatureCollection
+func geoJSON(config *Config, restQuery *RestQuery, entity interface{}) (interface{}, error) {
+	value := reflect.ValueOf(entity)
+	if !value.IsValid() || (value.Kind() == reflect.Ptr && value.IsNil()) {
+		return nil, NewErrorNotFound("resource not found")
+	}
+	page, isPage := entity.(*Page)
+	if isPage {
+		value = reflect.Indirect(reflect.ValueOf(page.Slice))
+		if value.Kind() != reflect.Slice {
+			return nil, NewErrorBadRequest("GeoJSON not supported for result")
+		}
+	}
+	elemType := reflect.Indirect(value).Type()
+	if isPage {
+		elemType = value.Type().Elem()
+	}
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	resource := config.GetResource(restQuery.Resource)
+	if resource == nil || resource.ResourceType() != elemType {
+		resource = config.getResourceByType(elemType)
+	}
+	if resource == nil || resource.Virtual() != nil {
+		return nil, NewErrorBadRequest("GeoJSON not supported for result")
+	}
+	field, err := resource.geometryTableField(config.DB())
+	if err != nil {
+		return nil, err
+	}
+	properties := selectedProperties(config.DB(), resource.ResourceType(), restQuery.Fields)
+	if !isPage {
+		return resource.selectedFeature(config.DB(), field, value, properties)
+	}
+	collection := &FeatureCollection{Type: "FeatureCollection", Features: make([]*Feature, value.Len()), NumberMatched: page.Count, NumberReturned: value.Len()}
+	for i := range collection.Features {
+		if collection.Features[i], err = resource.selectedFeature(config.DB(), field, value.Index(i), properties); err != nil {
+			return nil, err
+		}
+	}
+	return collection, nil
+}
+
+// checkGeoJSONWrite returns not acceptable error if result of write rest query can't be rendered as GeoJSON,
+// checked before execution so that nothing is written
+func checkGeoJSONWrite(config *Config, restQuery *RestQuery) error {
+	if restQuery.Action == Get {
+		return nil
+	}
+	notAcceptable := NewErrorNotAcceptable(fmt.Sprintf("GeoJSON not supported for query %v", restQuery))
+	if restQuery.Resource == BatchResource {
+		return notAcceptable
+	}
+	resource := config.GetResource(restQuery.Resource)
+	if resource == nil {
+		return nil
+	}
+	query := *restQuery
+	if restQuery.SubResource != "" && resource.Virtual() == nil {
+		rel, ok := config.DB().Table(resource.ResourceType()).Relations[restQuery.SubResource]
+		if !ok {
+			return nil
+		}
+		if resource = config.getResourceByType(rel.JoinTable.Type); resource == nil {
+			return nil
+		}
+		query.Key = restQuery.SubKey
+	}
+	if isBulk(&query) || resource.Virtual() != nil {
+		return notAcceptable
+	}
+	if _, err := resource.geometryTableField(config.DB()); err != nil {
+		return notAcceptable
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package brest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+type Place struct {
+	bun.BaseModel `bun:"table:place"`
+	ID            int64           `bun:",pk,autoincrement"`
+	Name          string          `json:"name"`
+	Location      *brest.Geometry `bun:"type:geometry" json:"location"`
+}
+
+var places = []Place{{
+	Name:     "Paris",
+	Location: brest.NewPoint(2.35, 48.85, 4326),
+}, {
+	Name:     "Seine",
+	Location: &brest.Geometry{Type: "LineString", Coordinates: [][]float64{{2.2, 48.8}, {2.35, 48.85}, {2.5, 48.9}}, SRID: 4326},
+}, {
+	Name: "Nowhere",
+}}
+
+func initGeoTests(t *testing.T) (*bun.DB, *brest.Config) {
+	db, config := initTests(t)
+	resource := brest.NewResource("Place", (*Place)(nil), brest.All)
+	resource.SetGeometryField("Location")
+	config.AddResource(resource)
+	db.ResetModel(context.Background(), (*Place)(nil))
+	for _, place := range places {
+		_, err := db.NewInsert().Model(&place).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+	return db, config
+}
+
+func TestGeometry(t *testing.T) {
+	var err error
+	var geometry brest.Geometry
+
+	err = geometry.Scan("0101000020E6100000000000000000F03F0000000000000040")
+	assert.Nil(t, err)
+	assert.Equal(t, "Point", geometry.Type)
+	assert.Equal(t, []float64{1, 2}, geometry.Coordinates)
+	assert.Equal(t, 4326, geometry.SRID)
+
+	ewkb, err := brest.NewPoint(1, 2, 4326).EWKB()
+	assert.Nil(t, err)
+	assert.Equal(t, "0101000020e6100000000000000000f03f0000000000000040", hex.EncodeToString(ewkb))
+
+	polygon := &brest.Geometry{Type: "MultiPolygon", Coordinates: [][][][]float64{{{{0, 0, 1}, {4, 0, 1}, {4, 4, 1}, {0, 0, 1}}}}, SRID: 2154}
+	for _, encode := range []func() ([]byte, error){polygon.EWKB, polygon.SpatiaLite} {
+		data, err := encode()
+		assert.Nil(t, err)
+		geometry = brest.Geometry{}
+		err = geometry.Scan(data)
+		assert.Nil(t, err)
+		assert.Equal(t, "MultiPolygon", geometry.Type)
+		assert.Equal(t, 2154, geometry.SRID)
+		assert.Equal(t, []interface{}{[]interface{}{[]interface{}{[]float64{0, 0, 1}, []float64{4, 0, 1}, []float64{4, 4, 1}, []float64{0, 0, 1}}}}, geometry.Coordinates)
+	}
+
+	err = geometry.Scan(`{"type":"Point","coordinates":[3,4]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Point", geometry.Type)
+
+	err = geometry.Scan([]byte{1, 2, 3})
+	assert.NotNil(t, err)
+}
+
+func TestGeoJSON(t *testing.T) {
+	db, config := initGeoTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	res, err := engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Place", Key: "2"})
+	assert.Nil(t, err)
+	place := res.(*Place)
+	assert.Equal(t, "LineString", place.Location.Type)
+	assert.Equal(t, 4326, place.Location.SRID)
+	assert.Equal(t, []interface{}{[]float64{2.2, 48.8}, []float64{2.35, 48.85}, []float64{2.5, 48.9}}, place.Location.Coordinates)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Place", Key: "3"})
+	assert.Nil(t, err)
+	assert.Nil(t, res.(*Place).Location)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Bbox: []float64{0, 0, 1, 1}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Place", Filter: &brest.Filter{Op: brest.Within, Attr: "location", Value: "not geojson"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+}
+
+func TestServerGeoJSON(t *testing.T) {
+	db, config := initGeoTests(t)
+	defer db.Close()
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var err error
+	var req *http.Request
+	var body []byte
+	var res *http.Response
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/Place?sort=id", bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	req.Header.Set("Accept", brest.GeoJson)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/geo+json; charset=utf-8", res.Header.Get("Content-Type"))
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	collection := map[string]interface{}{}
+	err = json.Unmarshal(body, &collection)
+	assert.Nil(t, err)
+	assert.Equal(t, "FeatureCollection", collection["type"])
+	assert.EqualValues(t, 3, collection["numberMatched"])
+	features := collection["features"].([]interface{})
+	assert.Equal(t, 3, len(features))
+	feature := features[0].(map[string]interface{})
+	assert.Equal(t, "Feature", feature["type"])
+	assert.EqualValues(t, 1, feature["id"])
+	assert.Equal(t, map[string]interface{}{"type": "Point", "coordinates": []interface{}{2.35, 48.85}}, feature["geometry"])
+	assert.Equal(t, map[string]interface{}{"ID": 1.0, "name": "Paris"}, feature["properties"])
+	assert.Nil(t, features[2].(map[string]interface{})["geometry"])
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/Place/1", bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	req.Header.Set("Accept", brest.GeoJson)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"type":"Feature","id":1,"geometry":{"type":"Point","coordinates":[2.35,48.85]},"properties":{"ID":1,"name":"Paris"}}`, string(body))
+
+	req, err = http.NewRequest("GET", ts.URL+"/rest/Book", bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	req.Header.Set("Accept", brest.GeoJson)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	// writes whose result can't be rendered as GeoJSON aren't executed
+	req, err = http.NewRequest("POST", ts.URL+"/rest/Book", bytes.NewBufferString(`{"Title":"Vol de nuit"}`))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", brest.Json)
+	req.Header.Set("Accept", brest.GeoJson)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	count, err := db.NewSelect().Model((*Book)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	req, err = http.NewRequest("POST", ts.URL+"/rest/Place", bytes.NewBufferString(`[{"name":"Lyon"}]`))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", brest.Json)
+	req.Header.Set("Accept", brest.GeoJson)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	req, err = http.NewRequest("POST", ts.URL+"/rest/Place", bytes.NewBufferString(`{"name":"Lyon"}`))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", brest.Json)
+	req.Header.Set("Accept", brest.GeoJson)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"type":"Feature","id":4,"geometry":null,"properties":{"ID":4,"name":"Lyon"}}`, string(body))
+
+	// nil result isn't found
+	config.GetResource("Place").AddHook(brest.BeforeGet, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		return brest.NewShortCircuit(nil)
+	})
+	req, err = http.NewRequest("GET", ts.URL+"/rest/Place/1", bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	req.Header.Set("Accept", brest.GeoJson)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	for _, entity := range []interface{}{nil, (*Place)(nil)} {
+		_, _, err = server.Serialize(&brest.RestQuery{Action: brest.Get, Resource: "Place", Key: "1", Accept: brest.GeoJson}, entity)
+		assert.NotNil(t, err)
+		assert.Equal(t, 404, err.(*brest.Error).Code)
+	}
+}
@@ -0,0 +1,619 @@
+package brest
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// Geometry GeoJSON geometry stored in spatial columns (PostGIS, SpatiaLite or MySQL),
+// positions are [x, y] or [x, y, z] arrays nested according to type
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates,omitempty"`
+	Geometries  []*Geometry `json:"geometries,omitempty"`
+	SRID        int         `json:"-"`
+}
+
+// NewPoint constructs point geometry
+func NewPoint(x float64, y float64, srid int) *Geometry {
+	return &Geometry{Type: "Point", Coordinates: []float64{x, y}, SRID: srid}
+}
+
+// geometryTypes maps GeoJSON type to WKB type
+var geometryTypes = map[string]uint32{
+	"Point":              1,
+	"LineString":         2,
+	"Polygon":            3,
+	"MultiPoint":         4,
+	"MultiLineString":    5,
+	"MultiPolygon":       6,
+	"GeometryCollection": 7,
+}
+
+// geometryDepths maps GeoJSON type to nesting depth of positions in coordinates
+var geometryDepths = map[string]int{
+	"Point":           0,
+	"LineString":      1,
+	"Polygon":         2,
+	"MultiPoint":      1,
+	"MultiLineString": 2,
+	"MultiPolygon":    3,
+}
+
+// geometryParts maps multi GeoJSON type to type of its parts
+var geometryParts = map[string]string{
+	"MultiPoint":      "Point",
+	"MultiLineString": "LineString",
+	"MultiPolygon":    "Polygon",
+}
+
+// geometryTypeName returns GeoJSON type of WKB type
+func geometryTypeName(wkbType uint32) (string, bool) {
+	for name, t := range geometryTypes {
+		if t == wkbType {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// Positions returns positions of geometry coordinates, nested as lines, rings and polygons according to type
+// (depth 0 for Point, 1 for LineString and MultiPoint, 2 for Polygon and MultiLineString, 3 for MultiPolygon)
+func (g *Geometry) Positions() (interface{}, error) {
+	depth, ok := geometryDepths[g.Type]
+	if !ok {
+		return nil, fmt.Errorf("geometry type '%v' has no coordinates", g.Type)
+	}
+	return positions(g.Coordinates, depth)
+}
+
+// positions converts coordinates of depth into []float64 positions nested in []interface{}
+func positions(coordinates interface{}, depth int) (interface{}, error) {
+	v := reflect.ValueOf(coordinates)
+	if coordinates == nil {
+		v = reflect.ValueOf([]interface{}{})
+	}
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("invalid coordinates '%v'", coordinates)
+	}
+	if depth == 0 {
+		position := make([]float64, v.Len())
+		for i := range position {
+			f, err := toFloat(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			position[i] = f
+		}
+		if len(position) == 1 || len(position) > 3 {
+			return nil, fmt.Errorf("invalid position '%v'", coordinates)
+		}
+		return position, nil
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		item, err := positions(v.Index(i).Interface(), depth-1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// toFloat converts number to float64
+func toFloat(value interface{}) (float64, error) {
+	switch n := value.(type) {
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	}
+	return 0, fmt.Errorf("invalid number '%v'", value)
+}
+
+// dimensions returns dimensions of first position of geometry, 2 if empty
+func (g *Geometry) dimensions() int {
+	if g.Type == "GeometryCollection" {
+		for _, child := range g.Geometries {
+			if child != nil {
+				return child.dimensions()
+			}
+		}
+		return 2
+	}
+	coordinates, err := g.Positions()
+	if err != nil {
+		return 2
+	}
+	for {
+		switch c := coordinates.(type) {
+		case []float64:
+			if len(c) == 3 {
+				return 3
+			}
+			return 2
+		case []interface{}:
+			if len(c) == 0 {
+				return 2
+			}
+			coordinates = c[0]
+		default:
+			return 2
+		}
+	}
+}
+
+// bounds returns min x, min y, max x and max y of geometry positions
+func (g *Geometry) bounds() (float64, float64, float64, float64) {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	var walk func(c interface{})
+	walk = func(c interface{}) {
+		switch c := c.(type) {
+		case []float64:
+			if len(c) >= 2 {
+				minX, minY = math.Min(minX, c[0]), math.Min(minY, c[1])
+				maxX, maxY = math.Max(maxX, c[0]), math.Max(maxY, c[1])
+			}
+		case []interface{}:
+			for _, item := range c {
+				walk(item)
+			}
+		}
+	}
+	if g.Type == "GeometryCollection" {
+		for _, child := range g.Geometries {
+			if child != nil {
+				x1, y1, x2, y2 := child.bounds()
+				minX, minY, maxX, maxY = math.Min(minX, x1), math.Min(minY, y1), math.Max(maxX, x2), math.Max(maxY, y2)
+			}
+		}
+	} else if coordinates, err := g.Positions(); err == nil {
+		walk(coordinates)
+	}
+	if minX > maxX {
+		return 0, 0, 0, 0
+	}
+	return minX, minY, maxX, maxY
+}
+
+// geometryWriter encodes geometries as WKB, EWKB or SpatiaLite blob (little endian)
+type geometryWriter struct {
+	buf        bytes.Buffer
+	spatiaLite bool
+	dims       int
+}
+
+func (w *geometryWriter) uint32(n uint32) {
+	binary.Write(&w.buf, binary.LittleEndian, n)
+}
+
+func (w *geometryWriter) float64(f float64) {
+	binary.Write(&w.buf, binary.LittleEndian, f)
+}
+
+func (w *geometryWriter) position(position []float64) {
+	for i := 0; i < w.dims; i++ {
+		if i < len(position) {
+			w.float64(position[i])
+		} else if len(position) == 0 {
+			w.float64(math.NaN())
+		} else {
+			w.float64(0)
+		}
+	}
+}
+
+func (w *geometryWriter) positions(items []interface{}) {
+	w.uint32(uint32(len(items)))
+	for _, item := range items {
+		w.position(item.([]float64))
+	}
+}
+
+// geometry writes geometry header (byte order and type for WKB, entity marker and type for SpatiaLite) and body,
+// srid is written in EWKB header if positive
+func (w *geometryWriter) geometry(g *Geometry, srid int, entity bool) error {
+	wkbType, ok := geometryTypes[g.Type]
+	if !ok {
+		return fmt.Errorf("unknown geometry type '%v'", g.Type)
+	}
+	if w.spatiaLite {
+		if entity {
+			w.buf.WriteByte(0x69)
+		}
+		if w.dims == 3 {
+			wkbType += 1000
+		}
+		w.uint32(wkbType)
+	} else {
+		w.buf.WriteByte(1)
+		if w.dims == 3 {
+			wkbType |= ewkbZ
+		}
+		if srid > 0 {
+			w.uint32(wkbType | ewkbSRID)
+			w.uint32(uint32(srid))
+		} else {
+			w.uint32(wkbType)
+		}
+	}
+	if g.Type == "GeometryCollection" {
+		w.uint32(uint32(len(g.Geometries)))
+		for _, child := range g.Geometries {
+			if err := w.geometry(child, 0, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	coordinates, err := g.Positions()
+	if err != nil {
+		return err
+	}
+	switch g.Type {
+	case "Point":
+		w.position(coordinates.([]float64))
+	case "LineString":
+		w.positions(coordinates.([]interface{}))
+	case "Polygon":
+		rings := coordinates.([]interface{})
+		w.uint32(uint32(len(rings)))
+		for _, ring := range rings {
+			w.positions(ring.([]interface{}))
+		}
+	default:
+		parts := coordinates.([]interface{})
+		w.uint32(uint32(len(parts)))
+		for _, part := range parts {
+			if err = w.geometry(&Geometry{Type: geometryParts[g.Type], Coordinates: part}, 0, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EWKB returns Extended WKB of geometry, SRID is included if positive
+func (g *Geometry) EWKB() ([]byte, error) {
+	w := &geometryWriter{dims: g.dimensions()}
+	if err := w.geometry(g, g.SRID, false); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// WKB returns WKB of geometry, 3D geometries use EWKB Z flag
+func (g *Geometry) WKB() ([]byte, error) {
+	w := &geometryWriter{dims: g.dimensions()}
+	if err := w.geometry(g, 0, false); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// SpatiaLite returns SpatiaLite blob of geometry
+func (g *Geometry) SpatiaLite() ([]byte, error) {
+	w := &geometryWriter{spatiaLite: true, dims: g.dimensions()}
+	minX, minY, maxX, maxY := g.bounds()
+	w.buf.WriteByte(0x00)
+	w.buf.WriteByte(0x01)
+	w.uint32(uint32(g.SRID))
+	for _, f := range []float64{minX, minY, maxX, maxY} {
+		w.float64(f)
+	}
+	w.buf.WriteByte(0x7C)
+	if err := w.geometry(g, 0, false); err != nil {
+		return nil, err
+	}
+	w.buf.WriteByte(0xFE)
+	return w.buf.Bytes(), nil
+}
+
+// geometryReader decodes geometries from WKB, EWKB or SpatiaLite blob
+type geometryReader struct {
+	data       []byte
+	pos        int
+	order      binary.ByteOrder
+	spatiaLite bool
+}
+
+func (r *geometryReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("unexpected end of geometry")
+	}
+	r.pos++
+	return r.data[r.pos-1], nil
+}
+
+func (r *geometryReader) uint32() (uint32, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, fmt.Errorf("unexpected end of geometry")
+	}
+	r.pos += 4
+	return r.order.Uint32(r.data[r.pos-4:]), nil
+}
+
+func (r *geometryReader) float64() (float64, error) {
+	if r.pos+8 > len(r.data) {
+		return 0, fmt.Errorf("unexpected end of geometry")
+	}
+	r.pos += 8
+	return math.Float64frombits(r.order.Uint64(r.data[r.pos-8:])), nil
+}
+
+// position reads position of dims values, M value is dropped
+func (r *geometryReader) position(dims int, hasM bool) ([]float64, error) {
+	position := make([]float64, 0, dims)
+	for i := 0; i < dims; i++ {
+		f, err := r.float64()
+		if err != nil {
+			return nil, err
+		}
+		if !(hasM && i == dims-1) {
+			position = append(position, f)
+		}
+	}
+	if math.IsNaN(position[0]) {
+		return []float64{}, nil
+	}
+	return position, nil
+}
+
+func (r *geometryReader) positions(dims int, hasM bool) ([]interface{}, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if int(n) > len(r.data) {
+		return nil, fmt.Errorf("invalid geometry count %v", n)
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		if items[i], err = r.position(dims, hasM); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// geometry reads geometry header and body
+func (r *geometryReader) geometry(entity bool) (*Geometry, error) {
+	g := new(Geometry)
+	if r.spatiaLite {
+		if entity {
+			if marker, err := r.byte(); err != nil || marker != 0x69 {
+				return nil, fmt.Errorf("invalid SpatiaLite geometry entity")
+			}
+		}
+	} else {
+		order, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		switch order {
+		case 0:
+			r.order = binary.BigEndian
+		case 1:
+			r.order = binary.LittleEndian
+		default:
+			return nil, fmt.Errorf("invalid WKB byte order %v", order)
+		}
+	}
+	wkbType, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	hasZ := wkbType&ewkbZ != 0
+	hasM := wkbType&ewkbM != 0
+	if wkbType&ewkbSRID != 0 {
+		srid, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		g.SRID = int(srid)
+	}
+	wkbType &= 0x0fffffff
+	if wkbType >= 1000000 {
+		return nil, fmt.Errorf("compressed SpatiaLite geometries not supported")
+	}
+	switch wkbType / 1000 {
+	case 1:
+		hasZ = true
+	case 2:
+		hasM = true
+	case 3:
+		hasZ, hasM = true, true
+	}
+	dims := 2
+	if hasZ {
+		dims++
+	}
+	if hasM {
+		dims++
+	}
+	name, ok := geometryTypeName(wkbType % 1000)
+	if !ok {
+		return nil, fmt.Errorf("unknown WKB geometry type %v", wkbType)
+	}
+	g.Type = name
+	switch name {
+	case "Point":
+		g.Coordinates, err = r.position(dims, hasM)
+	case "LineString":
+		g.Coordinates, err = r.positions(dims, hasM)
+	case "Polygon":
+		var n uint32
+		if n, err = r.uint32(); err != nil {
+			return nil, err
+		}
+		if int(n) > len(r.data) {
+			return nil, fmt.Errorf("invalid geometry count %v", n)
+		}
+		rings := make([]interface{}, n)
+		for i := range rings {
+			if rings[i], err = r.positions(dims, hasM); err != nil {
+				return nil, err
+			}
+		}
+		g.Coordinates = rings
+	default:
+		var n uint32
+		if n, err = r.uint32(); err != nil {
+			return nil, err
+		}
+		if int(n) > len(r.data) {
+			return nil, fmt.Errorf("invalid geometry count %v", n)
+		}
+		parts := make([]*Geometry, n)
+		for i := range parts {
+			if parts[i], err = r.geometry(true); err != nil {
+				return nil, err
+			}
+		}
+		if name == "GeometryCollection" {
+			g.Geometries = parts
+		} else {
+			coordinates := make([]interface{}, n)
+			for i, part := range parts {
+				coordinates[i] = part.Coordinates
+			}
+			g.Coordinates = coordinates
+		}
+	}
+	return g, err
+}
+
+// isSpatiaLite returns true if data is a SpatiaLite blob
+func isSpatiaLite(data []byte) bool {
+	return len(data) >= 44 && data[0] == 0x00 && (data[1] == 0 || data[1] == 1) && data[38] == 0x7C && data[len(data)-1] == 0xFE
+}
+
+// decodeGeometry decodes SpatiaLite blob, MySQL internal geometry (SRID followed by WKB) or (E)WKB
+func decodeGeometry(data []byte) (*Geometry, error) {
+	if isSpatiaLite(data) {
+		r := &geometryReader{data: data, pos: 39, spatiaLite: true, order: binary.LittleEndian}
+		if data[1] == 0 {
+			r.order = binary.BigEndian
+		}
+		g, err := r.geometry(false)
+		if err != nil {
+			return nil, err
+		}
+		g.SRID = int(r.order.Uint32(data[2:]))
+		return g, nil
+	}
+	r := &geometryReader{data: data}
+	g, err := r.geometry(false)
+	if err == nil && r.pos == len(data) {
+		return g, nil
+	}
+	if len(data) < 9 {
+		return nil, fmt.Errorf("invalid geometry")
+	}
+	r = &geometryReader{data: data[4:]}
+	if g, err = r.geometry(false); err != nil {
+		return nil, err
+	}
+	g.SRID = int(binary.LittleEndian.Uint32(data))
+	return g, nil
+}
+
+// Scan scans GeoJSON, hex encoded EWKB (Postgres), SpatiaLite blob, MySQL geometry or WKB
+func (g *Geometry) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		*g = Geometry{}
+		return nil
+	case string:
+		data = []byte(src)
+	case []byte:
+		data = src
+	default:
+		return fmt.Errorf("can't scan geometry from %T", src)
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		*g = Geometry{}
+		return json.Unmarshal(trimmed, g)
+	}
+	if decoded, err := hex.DecodeString(string(trimmed)); err == nil && len(decoded) > 0 {
+		data = decoded
+	}
+	decoded, err := decodeGeometry(data)
+	if err != nil {
+		return err
+	}
+	*g = *decoded
+	return nil
+}
+
+// Value returns hex encoded EWKB
+func (g *Geometry) Value() (driver.Value, error) {
+	if g == nil {
+		return nil, nil
+	}
+	ewkb, err := g.EWKB()
+	if err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString(ewkb), nil
+}
+
+// AppendQuery appends geometry in the format of the dialect: SpatiaLite blob for SQLite,
+// WKB with SRID for MySQL, hex encoded EWKB otherwise
+func (g *Geometry) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	if g == nil {
+		return append(b, "NULL"...), nil
+	}
+	switch fmter.Dialect().Name() {
+	case dialect.SQLite:
+		blob, err := g.SpatiaLite()
+		if err != nil {
+			return nil, err
+		}
+		return fmter.Dialect().AppendBytes(b, blob), nil
+	case dialect.MySQL:
+		wkb, err := g.WKB()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, "ST_GeomFromWKB("...)
+		b = fmter.Dialect().AppendBytes(b, wkb)
+		return append(b, fmt.Sprintf(", %d)", g.SRID)...), nil
+	}
+	ewkb, err := g.EWKB()
+	if err != nil {
+		return nil, err
+	}
+	return fmter.Dialect().AppendString(b, hex.EncodeToString(ewkb)), nil
+}
@@ -3,8 +3,12 @@ module github.com/aptogeo/brest
 go 1.18
 
 require (
+	github.com/stretchr/testify v1.8.2
 	github.com/uptrace/bun v1.1.12
+	github.com/uptrace/bun/dialect/sqlitedialect v1.1.12
+	github.com/uptrace/bun/driver/sqliteshim v1.1.12
 	github.com/vmihailenco/msgpack/v5 v5.3.5
+	modernc.org/sqlite v1.20.4
 )
 
 require (
@@ -13,15 +17,12 @@ require (
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
+	github.com/kr/text v0.2.0 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
 	github.com/mattn/go-sqlite3 v1.14.16 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
-	github.com/stretchr/objx v0.5.0 // indirect
-	github.com/stretchr/testify v1.8.2 // indirect
 	github.com/tmthrgd/go-hex v0.0.0-20190904060850-447a3041c3bc // indirect
-	github.com/uptrace/bun/dialect/sqlitedialect v1.1.12 // indirect
-	github.com/uptrace/bun/driver/sqliteshim v1.1.12 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/mod v0.8.0 // indirect
 	golang.org/x/sys v0.5.0 // indirect
@@ -34,7 +35,6 @@ require (
 	modernc.org/mathutil v1.5.0 // indirect
 	modernc.org/memory v1.5.0 // indirect
 	modernc.org/opt v0.1.3 // indirect
-	modernc.org/sqlite v1.20.4 // indirect
 	modernc.org/strutil v1.1.3 // indirect
 	modernc.org/token v1.1.0 // indirect
 )
@@ -0,0 +1,211 @@
+package brest
+
+import (
+	"context"
+	"errors"
+)
+
+// Hook defines execution callback function
+type Hook func(ctx context.Context, restQuery *RestQuery, entity interface{}) error
+
+// Event hook event type
+type Event string
+
+const (
+	// BeforeGet event before getting one entity
+	BeforeGet Event = "BeforeGet"
+	// AfterGet event after getting one entity
+	AfterGet Event = "AfterGet"
+	// BeforeList event before listing entities
+	BeforeList Event = "BeforeList"
+	// AfterList event after listing entities, called for each entity (not for aggregation rows)
+	AfterList Event = "AfterList"
+	// BeforeCreate event before creating entity (Post)
+	BeforeCreate Event = "BeforeCreate"
+	// AfterCreate event after creating entity (Post)
+	AfterCreate Event = "AfterCreate"
+	// BeforeUpdate event before updating entity (Put)
+	BeforeUpdate Event = "BeforeUpdate"
+	// AfterUpdate event after updating entity (Put)
+	AfterUpdate Event = "AfterUpdate"
+	// BeforePatch event before patching entity (Patch), entity only has key set, content is applied after hooks
+	BeforePatch Event = "BeforePatch"
+	// AfterPatch event after patching entity (Patch)
+	AfterPatch Event = "AfterPatch"
+	// BeforeDelete event before deleting entity
+	BeforeDelete Event = "BeforeDelete"
+	// AfterDelete event after deleting entity
+	AfterDelete Event = "AfterDelete"
+)
+
+// BeforeEvents all before events
+var BeforeEvents = []Event{BeforeGet, BeforeList, BeforeCreate, BeforeUpdate, BeforePatch, BeforeDelete}
+
+// AfterEvents all after events
+var AfterEvents = []Event{AfterGet, AfterList, AfterCreate, AfterUpdate, AfterPatch, AfterDelete}
+
+func (ev Event) String() string {
+	return string(ev)
+}
+
+// hookEvents returns before and after events for rest query
+func hookEvents(restQuery *RestQuery) (Event, Event) {
+	switch restQuery.Action {
+	case Get:
+		if restQuery.Key == "" {
+			return BeforeList, AfterList
+		}
+		return BeforeGet, AfterGet
+	case Post:
+		return BeforeCreate, AfterCreate
+	case Put:
+		return BeforeUpdate, AfterUpdate
+	case Patch:
+		return BeforePatch, AfterPatch
+	case Delete:
+		return BeforeDelete, AfterDelete
+	}
+	return "", ""
+}
+
+// hooks structure stores ordered hooks by event
+type hooks map[Event][]Hook
+
+func (h hooks) add(event Event, hook Hook) {
+	h[event] = append(h[event], hook)
+}
+
+func (h hooks) run(ctx context.Context, event Event, restQuery *RestQuery, entity interface{}) error {
+	for _, hook := range h[event] {
+		if err := hook(ctx, restQuery, entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShortCircuit error returned by hook to stop execution and respond with result
+type ShortCircuit struct {
+	Result interface{}
+}
+
+// NewShortCircuit constructs ShortCircuit
+func NewShortCircuit(result interface{}) *ShortCircuit {
+	return &ShortCircuit{Result: result}
+}
+
+// Error implements the error interface, pointer receiver so that only *ShortCircuit is an error
+func (s *ShortCircuit) Error() string {
+	return "short circuit"
+}
+
+// shortCircuitFromError retrieves ShortCircuit from error
+func shortCircuitFromError(err error) (*ShortCircuit, bool) {
+	var sc *ShortCircuit
+	if errors.As(err, &sc) {
+		return sc, true
+	}
+	return nil, false
+}
+
+// BeforeRestCreateHook interface implemented by model to be called before creation
+//
+// Model hook methods are prefixed by Rest so that a model may also implement bun query hooks
+type BeforeRestCreateHook interface {
+	BeforeRestCreate(ctx context.Context, restQuery *RestQuery) error
+}
+
+// AfterRestCreateHook interface implemented by model to be called after creation
+type AfterRestCreateHook interface {
+	AfterRestCreate(ctx context.Context, restQuery *RestQuery) error
+}
+
+// BeforeRestUpdateHook interface implemented by model to be called before update (Put)
+type BeforeRestUpdateHook interface {
+	BeforeRestUpdate(ctx context.Context, restQuery *RestQuery) error
+}
+
+// AfterRestUpdateHook interface implemented by model to be called after update (Put)
+type AfterRestUpdateHook interface {
+	AfterRestUpdate(ctx context.Context, restQuery *RestQuery) error
+}
+
+// BeforeRestPatchHook interface implemented by model to be called before patch
+type BeforeRestPatchHook interface {
+	BeforeRestPatch(ctx context.Context, restQuery *RestQuery) error
+}
+
+// AfterRestPatchHook interface implemented by model to be called after patch
+type AfterRestPatchHook interface {
+	AfterRestPatch(ctx context.Context, restQuery *RestQuery) error
+}
+
+// BeforeRestDeleteHook interface implemented by model to be called before deletion
+type BeforeRestDeleteHook interface {
+	BeforeRestDelete(ctx context.Context, restQuery *RestQuery) error
+}
+
+// AfterRestDeleteHook interface implemented by model to be called after deletion
+type AfterRestDeleteHook interface {
+	AfterRestDelete(ctx context.Context, restQuery *RestQuery) error
+}
+
+// AfterLoadHook interface implemented by model to be called after loading, for each element of a list
+type AfterLoadHook interface {
+	AfterLoad(ctx context.Context) error
+}
+
+// runModelHook calls model method matching event
+func runModelHook(ctx context.Context, event Event, restQuery *RestQuery, entity interface{}) error {
+	switch event {
+	case BeforeCreate:
+		if h, ok := entity.(BeforeRestCreateHook); ok {
+			return h.BeforeRestCreate(ctx, restQuery)
+		}
+	case AfterCreate:
+		if h, ok := entity.(AfterRestCreateHook); ok {
+			return h.AfterRestCreate(ctx, restQuery)
+		}
+	case BeforeUpdate:
+		if h, ok := entity.(BeforeRestUpdateHook); ok {
+			return h.BeforeRestUpdate(ctx, restQuery)
+		}
+	case AfterUpdate:
+		if h, ok := entity.(AfterRestUpdateHook); ok {
+			return h.AfterRestUpdate(ctx, restQuery)
+		}
+	case BeforePatch:
+		if h, ok := entity.(BeforeRestPatchHook); ok {
+			return h.BeforeRestPatch(ctx, restQuery)
+		}
+	case AfterPatch:
+		if h, ok := entity.(AfterRestPatchHook); ok {
+			return h.AfterRestPatch(ctx, restQuery)
+		}
+	case BeforeDelete:
+		if h, ok := entity.(BeforeRestDeleteHook); ok {
+			return h.BeforeRestDelete(ctx, restQuery)
+		}
+	case AfterDelete:
+		if h, ok := entity.(AfterRestDeleteHook); ok {
+			return h.AfterRestDelete(ctx, restQuery)
+		}
+	}
+	return nil
+}
+
+// runAfterLoadHook calls model AfterLoad method
+func runAfterLoadHook(ctx context.Context, entity interface{}) error {
+	if h, ok := entity.(AfterLoadHook); ok {
+		return h.AfterLoad(ctx)
+	}
+	return nil
+}
+
+// afterLoad calls model AfterLoad method then computes fields of resource computed by functions
+func (e *Engine) afterLoad(ctx context.Context, restQuery *RestQuery, resource *Resource, entity interface{}) error {
+	if err := runAfterLoadHook(ctx, entity); err != nil {
+		return err
+	}
+	return resource.computeFields(ctx, restQuery.Fields, entity)
+}
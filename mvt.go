@@ -0,0 +1,501 @@
+package brest
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const (
+	// tileExtent size of tile in tile coordinates
+	tileExtent = 4096
+	// tileBuffer size of buffer around tile in tile coordinates
+	tileBuffer = 64
+	// mercatorOrigin half circumference of Web Mercator (EPSG:3857)
+	mercatorOrigin = 20037508.342789244
+	// maxTileZoom maximum zoom level of tiles
+	maxTileZoom = 24
+	// maxTileFeatures default limit of features loaded for tile when database can't filter by bounding box
+	maxTileFeatures = 10000
+)
+
+// spatiaLiteDBs caches whether SQLite databases have SpatiaLite functions
+var spatiaLiteDBs sync.Map
+
+// bboxSupported returns true if database can filter geometries by bounding box,
+// SQLite needs SpatiaLite functions
+func bboxSupported(ctx context.Context, db *bun.DB) bool {
+	switch db.Dialect().Name() {
+	case dialect.PG, dialect.MySQL:
+		return true
+	case dialect.SQLite:
+		if supported, ok := spatiaLiteDBs.Load(db); ok {
+			return supported.(bool)
+		}
+		var version string
+		supported := db.NewRaw("SELECT spatialite_version()").Scan(ctx, &version) == nil
+		spatiaLiteDBs.Store(db, supported)
+		return supported
+	}
+	return false
+}
+
+// Tile structure
+type Tile struct {
+	Z int
+	X int
+	Y int
+}
+
+func (t *Tile) String() string {
+	return fmt.Sprintf("%v/%v/%v", t.Z, t.X, t.Y)
+}
+
+// VectorTile Mapbox Vector Tile (protobuf encoded)
+type VectorTile []byte
+
+// bounds returns Web Mercator bounds of tile
+func (t *Tile) bounds() (float64, float64, float64, float64) {
+	size := 2 * mercatorOrigin / math.Exp2(float64(t.Z))
+	minX := -mercatorOrigin + float64(t.X)*size
+	maxY := mercatorOrigin - float64(t.Y)*size
+	return minX, maxY - size, minX + size, maxY
+}
+
+// valid returns true if tile exists at its zoom level
+func (t *Tile) valid() bool {
+	if t.Z < 0 || t.Z > maxTileZoom {
+		return false
+	}
+	n := 1 << uint(t.Z)
+	return t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+// mercator projects position of srid to Web Mercator
+func mercator(srid int, position []float64) (float64, float64, error) {
+	switch srid {
+	case 3857, 900913:
+		return position[0], position[1], nil
+	case 4326:
+		lat := math.Max(math.Min(position[1], 85.05112878), -85.05112878)
+		x := position[0] * mercatorOrigin / 180
+		y := math.Log(math.Tan((90+lat)*math.Pi/360)) * mercatorOrigin / math.Pi
+		return x, y, nil
+	}
+	return 0, 0, NewErrorBadRequest(fmt.Sprintf("SRID %v not supported for tiles", srid))
+}
+
+// unmercator projects Web Mercator position to srid
+func unmercator(srid int, x float64, y float64) (float64, float64, error) {
+	switch srid {
+	case 3857, 900913:
+		return x, y, nil
+	case 4326:
+		return x * 180 / mercatorOrigin, math.Atan(math.Exp(y*math.Pi/mercatorOrigin))*360/math.Pi - 90, nil
+	}
+	return 0, 0, NewErrorBadRequest(fmt.Sprintf("SRID %v not supported for tiles", srid))
+}
+
+// executeTile executes vector tile rest query, features are loaded as a list of resource restricted to tile bounds,
+// at most maxTileFeatures features are loaded (unless limit is given) if database can't filter by bounding box
+func (e *Engine) executeTile(ctx context.Context, restQuery *RestQuery, resource *Resource) (interface{}, int, error) {
+	if restQuery.Action != Get {
+		return nil, 0, NewErrorBadRequest(fmt.Sprintf("action '%v' not supported for tiles", restQuery.Action))
+	}
+	tile := restQuery.Tile
+	if !tile.valid() {
+		return nil, 0, NewErrorBadRequest(fmt.Sprintf("invalid tile '%v'", tile))
+	}
+	field, err := resource.geometryTableField(e.config.DB())
+	if err != nil {
+		return nil, 0, err
+	}
+	tileQuery := *restQuery
+	tileQuery.Tile = nil
+	if len(restQuery.Fields) > 0 && !hasField(restQuery.Fields, field.GoName, field.Name) {
+		tileQuery.Fields = append(append([]*Field{}, restQuery.Fields...), &Field{field.Name})
+	}
+	minX, minY, maxX, maxY := tile.bounds()
+	buffer := (maxX - minX) * tileBuffer / tileExtent
+	if bboxSupported(ctx, e.config.DB()) {
+		x1, y1, err := unmercator(resource.GeometrySRID(), minX-buffer, minY-buffer)
+		if err != nil {
+			return nil, 0, err
+		}
+		x2, y2, err := unmercator(resource.GeometrySRID(), maxX+buffer, maxY+buffer)
+		if err != nil {
+			return nil, 0, err
+		}
+		tileQuery.Bbox = []float64{x1, y1, x2, y2}
+	} else if tileQuery.Limit == 0 {
+		tileQuery.Limit = maxTileFeatures
+	}
+	res, status, err := e.executeContext(ctx, &tileQuery)
+	if err != nil {
+		return nil, 0, err
+	}
+	page, ok := res.(*Page)
+	if !ok {
+		return res, status, nil
+	}
+	properties := selectedProperties(e.config.DB(), resource.ResourceType(), restQuery.Fields)
+	layer := newTileLayer(resource.Name())
+	slice := reflect.Indirect(reflect.ValueOf(page.Slice))
+	for i := 0; i < slice.Len(); i++ {
+		feature, err := resource.feature(e.config.DB(), field, slice.Index(i))
+		if err != nil {
+			return nil, 0, NewErrorFromCause(err)
+		}
+		if feature.Geometry == nil {
+			continue
+		}
+		srid := feature.Geometry.SRID
+		if srid == 0 {
+			srid = resource.GeometrySRID()
+		}
+		project := func(position []float64) (float64, float64, error) {
+			x, y, err := mercator(srid, position)
+			return (x - minX) / (maxX - minX) * tileExtent, (maxY - y) / (maxY - minY) * tileExtent, err
+		}
+		if err = layer.addFeature(feature, properties, project); err != nil {
+			return nil, 0, NewErrorFromCause(err)
+		}
+	}
+	return layer.tile(), status, nil
+}
+
+// hasField returns true if fields contain one of names (case insensitive)
+func hasField(fields []*Field, names ...string) bool {
+	for _, f := range fields {
+		for _, name := range names {
+			if strings.EqualFold(f.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// protobuf encodes protobuf messages
+type protobuf struct {
+	data []byte
+}
+
+func (p *protobuf) varint(v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	p.data = append(p.data, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func (p *protobuf) key(field int, wireType int) {
+	p.varint(uint64(field<<3 | wireType))
+}
+
+func (p *protobuf) uint(field int, v uint64) {
+	p.key(field, 0)
+	p.varint(v)
+}
+
+func (p *protobuf) bytes(field int, data []byte) {
+	p.key(field, 2)
+	p.varint(uint64(len(data)))
+	p.data = append(p.data, data...)
+}
+
+func (p *protobuf) double(field int, f float64) {
+	p.key(field, 1)
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(f))
+	p.data = append(p.data, buf...)
+}
+
+func (p *protobuf) packed(field int, values []uint32) {
+	packed := new(protobuf)
+	for _, v := range values {
+		packed.varint(uint64(v))
+	}
+	p.bytes(field, packed.data)
+}
+
+// tileLayer builds layer of vector tile
+type tileLayer struct {
+	name     string
+	features [][]byte
+	keys     []string
+	keyIndex map[string]uint32
+	values   [][]byte
+	valIndex map[string]uint32
+}
+
+func newTileLayer(name string) *tileLayer {
+	return &tileLayer{name: name, keyIndex: make(map[string]uint32), valIndex: make(map[string]uint32)}
+}
+
+// key returns index of property key
+func (l *tileLayer) key(key string) uint32 {
+	if index, ok := l.keyIndex[key]; ok {
+		return index
+	}
+	l.keyIndex[key] = uint32(len(l.keys))
+	l.keys = append(l.keys, key)
+	return l.keyIndex[key]
+}
+
+// value returns index of property value, false if value is null
+func (l *tileLayer) value(value interface{}) (uint32, bool) {
+	p := new(protobuf)
+	switch v := value.(type) {
+	case nil:
+		return 0, false
+	case string:
+		p.bytes(1, []byte(v))
+	case bool:
+		if v {
+			p.uint(7, 1)
+		} else {
+			p.uint(7, 0)
+		}
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			p.uint(4, uint64(i))
+		} else if f, err := v.Float64(); err == nil {
+			p.double(3, f)
+		} else {
+			p.bytes(1, []byte(v))
+		}
+	default:
+		data, _ := json.Marshal(v)
+		p.bytes(1, data)
+	}
+	encoded := string(p.data)
+	if index, ok := l.valIndex[encoded]; ok {
+		return index, true
+	}
+	l.valIndex[encoded] = uint32(len(l.values))
+	l.values = append(l.values, p.data)
+	return l.valIndex[encoded], true
+}
+
+// addFeature adds feature if its geometry intersects tile, properties are restricted to given JSON names if not nil
+func (l *tileLayer) addFeature(feature *Feature, properties map[string]bool, project func([]float64) (float64, float64, error)) error {
+	geometryType, commands, err := tileGeometry(feature.Geometry, project)
+	if err != nil || geometryType == 0 {
+		return err
+	}
+	p := new(protobuf)
+	if feature.ID != nil {
+		switch id := reflect.ValueOf(feature.ID); id.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if id.Int() >= 0 {
+				p.uint(1, uint64(id.Int()))
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			p.uint(1, id.Uint())
+		}
+	}
+	keys := make([]string, 0, len(feature.Properties))
+	for key := range feature.Properties {
+		if properties == nil || properties[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	tags := make([]uint32, 0)
+	for _, key := range keys {
+		if index, ok := l.value(feature.Properties[key]); ok {
+			tags = append(tags, l.key(key), index)
+		}
+	}
+	if len(tags) > 0 {
+		p.packed(2, tags)
+	}
+	p.uint(3, uint64(geometryType))
+	p.packed(4, commands)
+	l.features = append(l.features, p.data)
+	return nil
+}
+
+// tile returns encoded tile of layer, empty if layer has no feature
+func (l *tileLayer) tile() VectorTile {
+	if len(l.features) == 0 {
+		return VectorTile{}
+	}
+	layer := new(protobuf)
+	layer.uint(15, 2)
+	layer.bytes(1, []byte(l.name))
+	for _, feature := range l.features {
+		layer.bytes(2, feature)
+	}
+	for _, key := range l.keys {
+		layer.bytes(3, []byte(key))
+	}
+	for _, value := range l.values {
+		layer.bytes(4, value)
+	}
+	layer.uint(5, tileExtent)
+	tile := new(protobuf)
+	tile.bytes(3, layer.data)
+	return VectorTile(tile.data)
+}
+
+// geometryCommands encodes geometry commands with zigzag encoded deltas
+type geometryCommands struct {
+	commands []uint32
+	x, y     int64
+	minX     int64
+	minY     int64
+	maxX     int64
+	maxY     int64
+}
+
+func (c *geometryCommands) command(id uint32, count int) {
+	c.commands = append(c.commands, id&0x7|uint32(count)<<3)
+}
+
+func (c *geometryCommands) point(x int64, y int64) {
+	dx, dy := x-c.x, y-c.y
+	c.commands = append(c.commands, uint32((dx<<1)^(dx>>63)), uint32((dy<<1)^(dy>>63)))
+	c.x, c.y = x, y
+	c.minX, c.minY = minInt64(c.minX, x), minInt64(c.minY, y)
+	c.maxX, c.maxY = maxInt64(c.maxX, x), maxInt64(c.maxY, y)
+}
+
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// tilePoints projects positions to tile coordinates removing consecutive duplicates
+func tilePoints(items []interface{}, project func([]float64) (float64, float64, error)) ([][2]int64, error) {
+	points := make([][2]int64, 0, len(items))
+	for _, item := range items {
+		position := item.([]float64)
+		if len(position) < 2 {
+			continue
+		}
+		x, y, err := project(position)
+		if err != nil {
+			return nil, err
+		}
+		point := [2]int64{int64(math.Round(x)), int64(math.Round(y))}
+		if len(points) == 0 || points[len(points)-1] != point {
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
+
+// line adds line commands, ring is closed with ClosePath and oriented clockwise if exterior
+func (c *geometryCommands) line(points [][2]int64, ring bool, exterior bool) {
+	if ring {
+		if len(points) > 1 && points[0] == points[len(points)-1] {
+			points = points[:len(points)-1]
+		}
+		if len(points) < 3 {
+			return
+		}
+		area := int64(0)
+		for i := range points {
+			j := (i + 1) % len(points)
+			area += points[i][0]*points[j][1] - points[j][0]*points[i][1]
+		}
+		if area == 0 {
+			return
+		}
+		if (area > 0) != exterior {
+			for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+				points[i], points[j] = points[j], points[i]
+			}
+		}
+	} else if len(points) < 2 {
+		return
+	}
+	c.command(1, 1)
+	c.point(points[0][0], points[0][1])
+	c.command(2, len(points)-1)
+	for _, point := range points[1:] {
+		c.point(point[0], point[1])
+	}
+	if ring {
+		c.command(7, 1)
+	}
+}
+
+// tileGeometry returns type and commands of geometry in tile coordinates,
+// type is 0 if geometry is empty, outside tile or a geometry collection
+func tileGeometry(g *Geometry, project func([]float64) (float64, float64, error)) (uint32, []uint32, error) {
+	coordinates, err := g.Positions()
+	if err != nil {
+		return 0, nil, nil
+	}
+	c := &geometryCommands{minX: math.MaxInt64, minY: math.MaxInt64, maxX: math.MinInt64, maxY: math.MinInt64}
+	var geometryType uint32
+	switch g.Type {
+	case "Point", "MultiPoint":
+		geometryType = 1
+		items := []interface{}{coordinates}
+		if g.Type == "MultiPoint" {
+			items = coordinates.([]interface{})
+		}
+		points, err := tilePoints(items, project)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(points) > 0 {
+			c.command(1, len(points))
+			for _, point := range points {
+				c.point(point[0], point[1])
+			}
+		}
+	case "LineString", "MultiLineString":
+		geometryType = 2
+		lines := []interface{}{coordinates}
+		if g.Type == "MultiLineString" {
+			lines = coordinates.([]interface{})
+		}
+		for _, line := range lines {
+			points, err := tilePoints(line.([]interface{}), project)
+			if err != nil {
+				return 0, nil, err
+			}
+			c.line(points, false, false)
+		}
+	case "Polygon", "MultiPolygon":
+		geometryType = 3
+		polygons := []interface{}{coordinates}
+		if g.Type == "MultiPolygon" {
+			polygons = coordinates.([]interface{})
+		}
+		for _, polygon := range polygons {
+			for i, ring := range polygon.([]interface{}) {
+				points, err := tilePoints(ring.([]interface{}), project)
+				if err != nil {
+					return 0, nil, err
+				}
+				c.line(points, true, i == 0)
+			}
+		}
+	}
+	if len(c.commands) == 0 || c.maxX < -tileBuffer || c.maxY < -tileBuffer || c.minX > tileExtent+tileBuffer || c.minY > tileExtent+tileBuffer {
+		return 0, nil, nil
+	}
+	return geometryType, c.commands, nil
+}
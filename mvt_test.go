@@ -0,0 +1,169 @@
+package brest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+type pbField struct {
+	num    int
+	varint uint64
+	data   []byte
+}
+
+// decodePb decodes protobuf message fields (varint and length delimited only)
+func decodePb(t *testing.T, data []byte) []pbField {
+	fields := make([]pbField, 0)
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		field := pbField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			field.varint, n = binary.Uvarint(data)
+			data = data[n:]
+		case 1:
+			field.data = data[:8]
+			data = data[8:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			field.data = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %v", key&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// decodePacked decodes packed varints
+func decodePacked(data []byte) []uint32 {
+	values := make([]uint32, 0)
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		values = append(values, uint32(v))
+		data = data[n:]
+	}
+	return values
+}
+
+type tileLayer struct {
+	name     string
+	extent   uint64
+	keys     []string
+	features [][]pbField
+}
+
+func decodeTile(t *testing.T, data []byte) []*tileLayer {
+	layers := make([]*tileLayer, 0)
+	for _, f := range decodePb(t, data) {
+		assert.Equal(t, 3, f.num)
+		layer := new(tileLayer)
+		for _, lf := range decodePb(t, f.data) {
+			switch lf.num {
+			case 1:
+				layer.name = string(lf.data)
+			case 2:
+				layer.features = append(layer.features, decodePb(t, lf.data))
+			case 3:
+				layer.keys = append(layer.keys, string(lf.data))
+			case 5:
+				layer.extent = lf.varint
+			}
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+func getTile(t *testing.T, ts *httptest.Server, uri string) (int, []byte) {
+	req, err := http.NewRequest("GET", ts.URL+uri, bytes.NewBufferString(""))
+	assert.Nil(t, err)
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	if res.StatusCode == http.StatusOK {
+		assert.Equal(t, brest.Mvt, res.Header.Get("Content-Type"))
+	}
+	return res.StatusCode, body
+}
+
+func TestServerVectorTiles(t *testing.T) {
+	db, config := initGeoTests(t)
+	defer db.Close()
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	status, body := getTile(t, ts, "/rest/Place/tiles/0/0/0.mvt")
+	assert.Equal(t, http.StatusOK, status)
+	layers := decodeTile(t, body)
+	assert.Equal(t, 1, len(layers))
+	assert.Equal(t, "Place", layers[0].name)
+	assert.EqualValues(t, 4096, layers[0].extent)
+	assert.Equal(t, []string{"ID", "name"}, layers[0].keys)
+	assert.Equal(t, 2, len(layers[0].features))
+	point := layers[0].features[0]
+	assert.Equal(t, pbField{num: 1, varint: 1}, point[0])
+	assert.Equal(t, []uint32{0, 0, 1, 1}, decodePacked(point[1].data))
+	assert.Equal(t, pbField{num: 3, varint: 1}, point[2])
+	// MoveTo(1) of zigzag encoded (2075, 1409)
+	assert.Equal(t, []uint32{9, 4150, 2818}, decodePacked(point[3].data))
+	line := layers[0].features[1]
+	assert.Equal(t, pbField{num: 3, varint: 2}, line[2])
+	commands := decodePacked(line[3].data)
+	assert.Equal(t, uint32(9), commands[0])
+	assert.Equal(t, uint32(2|2<<3), commands[3])
+
+	status, body = getTile(t, ts, "/rest/Place/tiles/1/1/0.mvt?fields=name")
+	assert.Equal(t, http.StatusOK, status)
+	layers = decodeTile(t, body)
+	assert.Equal(t, []string{"name"}, layers[0].keys)
+	assert.Equal(t, 2, len(layers[0].features))
+	// pk is always selected, features keep their id
+	assert.Equal(t, 4, len(layers[0].features[0]))
+	assert.Equal(t, pbField{num: 1, varint: 1}, layers[0].features[0][0])
+
+	status, body = getTile(t, ts, "/rest/Place/tiles/1/1/1.mvt")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 0, len(body))
+
+	status, body = getTile(t, ts, "/rest/Place/tiles/0/0/0.mvt?filter="+url.QueryEscape(`{"Op":"eq","Attr":"name","Value":"Seine"}`))
+	assert.Equal(t, http.StatusOK, status)
+	layers = decodeTile(t, body)
+	assert.Equal(t, 1, len(layers[0].features))
+	assert.Equal(t, pbField{num: 1, varint: 2}, layers[0].features[0][0])
+
+	status, _ = getTile(t, ts, "/rest/Place/tiles/1/2/0.mvt")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = getTile(t, ts, "/rest/Book/tiles/0/0/0.mvt")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// without SpatiaLite, features aren't filtered by bounding box but their number is limited
+	queries := make([]string, 0)
+	config.GetResource("Place").AddSelectQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.SelectQuery) error {
+		queries = append(queries, query.String())
+		return nil
+	})
+	status, _ = getTile(t, ts, "/rest/Place/tiles/0/0/0.mvt")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, len(queries))
+	assert.Contains(t, queries[0], "LIMIT 10000")
+	assert.NotContains(t, queries[0], "MbrIntersects")
+}
@@ -0,0 +1,541 @@
+package brest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Orphan type defines behaviour for children omitted from a nested update
+type Orphan string
+
+const (
+	// OrphanKeep keeps omitted children
+	OrphanKeep Orphan = "Keep"
+	// OrphanUnlink unlinks omitted children (foreign key set to null, m2m link removed)
+	OrphanUnlink Orphan = "Unlink"
+	// OrphanDelete deletes omitted children
+	OrphanDelete Orphan = "Delete"
+)
+
+// relationKind returns relation kind from bun tag (belongs-to, has-one, has-many or m2m)
+func relationKind(rel *schema.Relation) string {
+	if rel.M2MTable != nil {
+		return "m2m"
+	}
+	kind, _ := rel.Field.Tag.Option("rel")
+	return kind
+}
+
+// NestedExecFunc wraps execution function writing nested relations of entity in the same transaction
+//
+// Belongs-to parents are written before entity, has-one, has-many and m2m children after.
+// Children without pk are inserted, others are updated with fields present in payload (non zero fields
+// if payload is unknown), so that a child containing only its pk is linked without being modified.
+// Children are written as their resource would: its actions must allow the write and its hooks run.
+// Existing children not related to entity yet can't be updated (has-many children can't be moved).
+func (e *Executor) NestedExecFunc(execFunc ExecFunc) ExecFunc {
+	return func(ctx context.Context, tx *bun.Tx) error {
+		if e.resource == nil || len(e.resource.nestedRelations) == 0 {
+			return execFunc(ctx, tx)
+		}
+		table := tx.Dialect().Tables().Get(reflect.TypeOf(e.entity))
+		elem := reflect.ValueOf(e.entity).Elem()
+		names := make([]string, 0, len(e.resource.nestedRelations))
+		for name := range e.resource.nestedRelations {
+			if _, ok := table.Relations[name]; !ok {
+				return NewErrorBadRequest(fmt.Sprintf("unknown relation '%v' for resource '%v'", name, e.resource.Name()))
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		w := &nestedWriter{engine: &Engine{config: e.config}, restQuery: e.restQuery, tx: tx}
+		for _, name := range names {
+			rel := table.Relations[name]
+			if relationKind(rel) != "belongs-to" {
+				continue
+			}
+			written, err := w.writeBelongsTo(ctx, table, rel, elem, relationPayload(e.payload, rel))
+			if err != nil {
+				return err
+			}
+			if written && e.columns != nil {
+				// foreign keys set from parent are updated by Patch
+				for _, base := range rel.BaseFields {
+					e.columns = append(e.columns, base.Name)
+				}
+			}
+		}
+		if err := execFunc(ctx, tx); err != nil {
+			return err
+		}
+		orphan := OrphanKeep
+		for _, name := range names {
+			rel := table.Relations[name]
+			if e.restQuery.Action == Put {
+				orphan = e.resource.nestedRelations[name]
+			}
+			var err error
+			switch relationKind(rel) {
+			case "has-one", "has-many":
+				err = w.writeHasMany(ctx, rel, elem, relationPayload(e.payload, rel), orphan)
+			case "m2m":
+				err = w.writeM2M(ctx, rel, elem, relationPayload(e.payload, rel), orphan)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// relationValue returns relation field value, false if relation is not provided (nil)
+func relationValue(rel *schema.Relation, elem reflect.Value) (reflect.Value, bool) {
+	v := elem
+	for _, i := range rel.Field.Index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Slice) && v.IsNil() {
+		return v, false
+	}
+	return v, true
+}
+
+// payloadDocument decodes JSON or msgpack content of rest query, nil if content can't be decoded
+func payloadDocument(restQuery *RestQuery) interface{} {
+	content, ok := restQuery.Content.([]byte)
+	if !ok {
+		return nil
+	}
+	var doc interface{}
+	if regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.ContentType) {
+		if err := decodeJSON(content, &doc); err != nil {
+			return nil
+		}
+	} else if regexp.MustCompile("[+-/](msgpack|messagepack)($|[+-])").MatchString(restQuery.ContentType) {
+		if err := msgpack.Unmarshal(content, &doc); err != nil {
+			return nil
+		}
+	}
+	return doc
+}
+
+// relationPayload returns payload of relation in entity payload, nil if unknown
+func relationPayload(payload interface{}, rel *schema.Relation) interface{} {
+	object, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	name := jsonName(rel.Field)
+	for key, value := range object {
+		// encoding/json matches keys case-insensitively
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
+
+// payloadKeys returns keys of object payload, nil if payload isn't an object
+func payloadKeys(payload interface{}) []string {
+	object, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// children returns addressable children elements of relation value with their payload keys
+func children(v reflect.Value, payload interface{}) ([]reflect.Value, [][]string) {
+	elems := make([]reflect.Value, 0)
+	keys := make([][]string, 0)
+	if v.Kind() != reflect.Slice {
+		return append(elems, reflect.Indirect(v)), append(keys, payloadKeys(payload))
+	}
+	items, _ := payload.([]interface{})
+	for i := 0; i < v.Len(); i++ {
+		child := v.Index(i)
+		if child.Kind() == reflect.Ptr {
+			if child.IsNil() {
+				continue
+			}
+			child = child.Elem()
+		}
+		elems = append(elems, child)
+		if i < len(items) {
+			keys = append(keys, payloadKeys(items[i]))
+		} else {
+			keys = append(keys, nil)
+		}
+	}
+	return elems, keys
+}
+
+// nestedWriter writes children of entity with permissions, hooks and query hooks of their resources
+type nestedWriter struct {
+	engine    *Engine
+	restQuery *RestQuery
+	tx        *bun.Tx
+}
+
+// childResource returns resource of relation children
+func (w *nestedWriter) childResource(rel *schema.Relation) (*Resource, error) {
+	resource := w.engine.config.getResourceByType(rel.JoinTable.Type)
+	if resource == nil {
+		return nil, NewErrorBadRequest(fmt.Sprintf("no resource for relation '%v'", rel.Field.GoName))
+	}
+	return resource, nil
+}
+
+// write runs execution of action on child if resource allows it, between before and after hooks of resource,
+// child short circuited by a before hook isn't written
+func (w *nestedWriter) write(ctx context.Context, resource *Resource, action Action, child reflect.Value, exec func(ctx context.Context, executor *Executor) error) error {
+	if resource.Action()&action == 0 {
+		return NewErrorForbbiden(fmt.Sprintf("nested %v not authorized for resource %v", action, resource.Name()))
+	}
+	restQuery := &RestQuery{Request: w.restQuery.Request, Action: action, Resource: resource.Name(), Debug: w.restQuery.Debug}
+	if action != Post {
+		key, err := resource.pkKey(w.engine.config.db, child)
+		if err != nil {
+			return NewErrorFromCause(err)
+		}
+		restQuery.Key = key
+	}
+	entity := child.Addr().Interface()
+	beforeEvent, afterEvent := hookEvents(restQuery)
+	if err := w.engine.runBeforeHooks(ctx, beforeEvent, restQuery, resource, entity); err != nil {
+		if _, ok := shortCircuitFromError(err); ok {
+			return nil
+		}
+		return NewErrorFromCause(err)
+	}
+	if err := exec(ctx, NewExecutor(w.engine.config, restQuery, entity)); err != nil {
+		return NewErrorFromCause(err)
+	}
+	if err := w.engine.runAfterHooks(ctx, afterEvent, restQuery, resource, entity); err != nil {
+		if _, ok := shortCircuitFromError(err); ok {
+			return nil
+		}
+		return NewErrorFromCause(err)
+	}
+	return nil
+}
+
+// insert inserts child
+func (w *nestedWriter) insert(ctx context.Context, resource *Resource, child reflect.Value) error {
+	return w.write(ctx, resource, Post, child, func(ctx context.Context, executor *Executor) error {
+		q := w.tx.NewInsert().Model(executor.entity)
+		if err := executor.runInsertQueryHooks(ctx, q); err != nil {
+			return err
+		}
+		_, err := q.Exec(ctx)
+		return err
+	})
+}
+
+// update updates columns of child, null columns are set to NULL
+func (w *nestedWriter) update(ctx context.Context, resource *Resource, table *schema.Table, child reflect.Value, columns []string, nullColumns []string) error {
+	return w.write(ctx, resource, Patch, child, func(ctx context.Context, executor *Executor) error {
+		q := w.tx.NewUpdate().Model(executor.entity)
+		if len(columns) > 0 {
+			q = q.Column(columns...)
+		}
+		for _, column := range nullColumns {
+			q = q.Set("? = NULL", bun.Ident(column))
+		}
+		q = q.ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+			return pkWhere(q, table, child)
+		})
+		if err := executor.runUpdateQueryHooks(ctx, q); err != nil {
+			return err
+		}
+		_, err := q.Exec(ctx)
+		return err
+	})
+}
+
+// delete deletes child
+func (w *nestedWriter) delete(ctx context.Context, resource *Resource, table *schema.Table, child reflect.Value) error {
+	return w.write(ctx, resource, Delete, child, func(ctx context.Context, executor *Executor) error {
+		q := w.tx.NewDelete().Model(executor.entity).ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+			return pkWhere(q, table, child)
+		})
+		if err := executor.runDeleteQueryHooks(ctx, q); err != nil {
+			return err
+		}
+		_, err := q.Exec(ctx)
+		return err
+	})
+}
+
+// pkWhere restricts query to row of table with pk of elem
+func pkWhere(q bun.QueryBuilder, table *schema.Table, elem reflect.Value) bun.QueryBuilder {
+	for _, pk := range table.PKs {
+		q = q.Where("? = ?", bun.Ident(pk.Name), pk.Value(elem).Interface())
+	}
+	return q
+}
+
+// hasPk returns true if all pk fields of elem are set
+func hasPk(table *schema.Table, elem reflect.Value) bool {
+	for _, pk := range table.PKs {
+		if pk.Value(elem).IsZero() {
+			return false
+		}
+	}
+	return len(table.PKs) > 0
+}
+
+// saveChild inserts child without pk (or missing), updates existing child with fields of payload keys (non zero fields
+// if keys are nil); existing child out of scope of entity may only be linked, it is not found if fields would be updated
+func (w *nestedWriter) saveChild(ctx context.Context, rel *schema.Relation, child reflect.Value, keys []string, inScope func(ctx context.Context) (bool, error)) error {
+	resource, err := w.childResource(rel)
+	if err != nil {
+		return err
+	}
+	table := rel.JoinTable
+	if hasPk(table, child) {
+		exists, err := w.tx.NewSelect().Model(reflect.New(table.Type).Interface()).ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+			return pkWhere(q, table, child)
+		}).Exists(ctx)
+		if err != nil {
+			return NewErrorFromCause(err)
+		}
+		if exists {
+			var columns []string
+			if keys != nil {
+				columns = jsonKeysToColumns(table, keys)
+			} else {
+				columns = make([]string, 0)
+				for _, field := range table.DataFields {
+					if !field.Value(child).IsZero() {
+						columns = append(columns, field.Name)
+					}
+				}
+			}
+			if len(columns) == 0 {
+				return nil
+			}
+			in, err := inScope(ctx)
+			if err != nil {
+				return NewErrorFromCause(err)
+			}
+			if !in {
+				key, _ := resource.pkKey(w.engine.config.db, child)
+				return NewErrorNotFound(fmt.Sprintf("resource '%v' with key '%v' not found", resource.Name(), key))
+			}
+			return w.update(ctx, resource, table, child, columns, nil)
+		}
+	}
+	return w.insert(ctx, resource, child)
+}
+
+// writeBelongsTo saves parent and sets foreign keys of entity, returns false if parent is not provided,
+// parent is in scope if entity already references it
+func (w *nestedWriter) writeBelongsTo(ctx context.Context, table *schema.Table, rel *schema.Relation, elem reflect.Value, payload interface{}) (bool, error) {
+	v, ok := relationValue(rel, elem)
+	if !ok {
+		return false, nil
+	}
+	parent := reflect.Indirect(v)
+	inScope := func(ctx context.Context) (bool, error) {
+		if !hasPk(table, elem) {
+			return false, nil
+		}
+		return w.tx.NewSelect().Model(reflect.New(table.Type).Interface()).ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+			q = pkWhere(q, table, elem)
+			for i, base := range rel.BaseFields {
+				q = q.Where("? = ?", bun.Ident(base.Name), rel.JoinFields[i].Value(parent).Interface())
+			}
+			return q
+		}).Exists(ctx)
+	}
+	if err := w.saveChild(ctx, rel, parent, payloadKeys(payload), inScope); err != nil {
+		return false, err
+	}
+	if !hasPk(rel.JoinTable, parent) {
+		// short circuited
+		return false, nil
+	}
+	for i, base := range rel.BaseFields {
+		base.Value(elem).Set(rel.JoinFields[i].Value(parent))
+	}
+	return true, nil
+}
+
+// writeHasMany saves has-one or has-many children and handles orphans, children are in scope if they reference entity
+func (w *nestedWriter) writeHasMany(ctx context.Context, rel *schema.Relation, elem reflect.Value, payload interface{}, orphan Orphan) error {
+	v, ok := relationValue(rel, elem)
+	if !ok {
+		return nil
+	}
+	parentWhere := func(q bun.QueryBuilder) bun.QueryBuilder {
+		for i, join := range rel.JoinFields {
+			q = q.Where("? = ?", bun.Ident(join.Name), rel.BaseFields[i].Value(elem).Interface())
+		}
+		return q
+	}
+	keys := make([]interface{}, 0)
+	elems, childrenKeys := children(v, payload)
+	for j, child := range elems {
+		for i, join := range rel.JoinFields {
+			join.Value(child).Set(rel.BaseFields[i].Value(elem))
+		}
+		childKeys := childrenKeys[j]
+		if childKeys != nil {
+			// foreign keys are set from entity
+			for _, join := range rel.JoinFields {
+				childKeys = append(childKeys, jsonName(join))
+			}
+		}
+		child := child
+		inScope := func(ctx context.Context) (bool, error) {
+			return w.tx.NewSelect().Model(reflect.New(rel.JoinTable.Type).Interface()).ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+				return parentWhere(pkWhere(q, rel.JoinTable, child))
+			}).Exists(ctx)
+		}
+		if err := w.saveChild(ctx, rel, child, childKeys, inScope); err != nil {
+			return err
+		}
+		if len(rel.JoinTable.PKs) == 1 && hasPk(rel.JoinTable, child) {
+			keys = append(keys, rel.JoinTable.PKs[0].Value(child).Interface())
+		}
+	}
+	if orphan == OrphanKeep {
+		return nil
+	}
+	if len(rel.JoinTable.PKs) != 1 {
+		return NewErrorBadRequest(fmt.Sprintf("orphans of relation '%v' need single pk", rel.Field.GoName))
+	}
+	orphans := reflect.New(reflect.SliceOf(rel.JoinTable.Type))
+	err := w.tx.NewSelect().Model(orphans.Interface()).ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+		q = parentWhere(q)
+		if len(keys) > 0 {
+			q = q.Where("? NOT IN (?)", bun.Ident(rel.JoinTable.PKs[0].Name), bun.In(keys))
+		}
+		return q
+	}).Scan(ctx)
+	if err != nil {
+		return NewErrorFromCause(err)
+	}
+	resource, err := w.childResource(rel)
+	if err != nil {
+		return err
+	}
+	nullColumns := make([]string, 0, len(rel.JoinFields))
+	for _, join := range rel.JoinFields {
+		nullColumns = append(nullColumns, join.Name)
+	}
+	for i := 0; i < orphans.Elem().Len(); i++ {
+		child := orphans.Elem().Index(i)
+		if orphan == OrphanDelete {
+			err = w.delete(ctx, resource, rel.JoinTable, child)
+		} else {
+			err = w.update(ctx, resource, rel.JoinTable, child, nil, nullColumns)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeM2M saves m2m children, links them to entity and handles orphans, children are in scope if they are linked
+// to entity, orphans deleted are those no longer linked to any entity
+func (w *nestedWriter) writeM2M(ctx context.Context, rel *schema.Relation, elem reflect.Value, payload interface{}, orphan Orphan) error {
+	v, ok := relationValue(rel, elem)
+	if !ok {
+		return nil
+	}
+	if len(rel.JoinTable.PKs) != 1 {
+		return NewErrorBadRequest(fmt.Sprintf("m2m relation '%v' needs single pk", rel.Field.GoName))
+	}
+	baseWhere := func(q bun.QueryBuilder) bun.QueryBuilder {
+		for i, m2mBase := range rel.M2MBaseFields {
+			q = q.Where("? = ?", bun.Ident(m2mBase.Name), rel.BaseFields[i].Value(elem).Interface())
+		}
+		return q
+	}
+	keys := make([]interface{}, 0)
+	elems, childrenKeys := children(v, payload)
+	for j, child := range elems {
+		child := child
+		linked := func(ctx context.Context) (bool, error) {
+			return w.tx.NewSelect().Model(reflect.New(rel.M2MTable.Type).Interface()).ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+				return m2mLinkWhere(q, rel, elem, child)
+			}).Exists(ctx)
+		}
+		if err := w.saveChild(ctx, rel, child, childrenKeys[j], linked); err != nil {
+			return err
+		}
+		if !hasPk(rel.JoinTable, child) {
+			// short circuited
+			continue
+		}
+		keys = append(keys, rel.JoinTable.PKs[0].Value(child).Interface())
+		if err := linkM2M(ctx, w.tx, rel, elem, child); err != nil {
+			return NewErrorFromCause(err)
+		}
+	}
+	if orphan == OrphanKeep {
+		return nil
+	}
+	m2mJoin := rel.M2MJoinFields[0]
+	orphanWhere := func(q bun.QueryBuilder) bun.QueryBuilder {
+		q = baseWhere(q)
+		if len(keys) > 0 {
+			q = q.Where("? NOT IN (?)", bun.Ident(m2mJoin.Name), bun.In(keys))
+		}
+		return q
+	}
+	orphanKeys := make([]interface{}, 0)
+	if orphan == OrphanDelete {
+		if err := w.tx.NewSelect().Model(reflect.New(rel.M2MTable.Type).Interface()).Column(m2mJoin.Name).ApplyQueryBuilder(orphanWhere).Scan(ctx, &orphanKeys); err != nil {
+			return NewErrorFromCause(err)
+		}
+	}
+	if _, err := w.tx.NewDelete().Model(reflect.New(rel.M2MTable.Type).Interface()).ApplyQueryBuilder(orphanWhere).Exec(ctx); err != nil {
+		return NewErrorFromCause(err)
+	}
+	if len(orphanKeys) == 0 {
+		return nil
+	}
+	// children still linked to other entities are kept
+	links := w.tx.NewSelect().Model(reflect.New(rel.M2MTable.Type).Interface()).Column(m2mJoin.Name)
+	orphans := reflect.New(reflect.SliceOf(rel.JoinTable.Type))
+	err := w.tx.NewSelect().Model(orphans.Interface()).
+		Where("? IN (?)", bun.Ident(rel.JoinTable.PKs[0].Name), bun.In(orphanKeys)).
+		Where("? NOT IN (?)", bun.Ident(rel.JoinTable.PKs[0].Name), links).
+		Scan(ctx)
+	if err != nil {
+		return NewErrorFromCause(err)
+	}
+	resource, err := w.childResource(rel)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < orphans.Elem().Len(); i++ {
+		if err = w.delete(ctx, resource, rel.JoinTable, orphans.Elem().Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
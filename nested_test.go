@@ -0,0 +1,199 @@
+package brest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+type Article struct {
+	ID     int `bun:",pk,autoincrement"`
+	Title  string
+	Labels []*Label `bun:"m2m:article_labels,join:Article=Label"`
+}
+
+type Label struct {
+	ID   int `bun:",pk,autoincrement"`
+	Name string
+}
+
+type ArticleLabel struct {
+	ArticleID int      `bun:",pk"`
+	Article   *Article `bun:"rel:belongs-to,join:article_id=id"`
+	LabelID   int      `bun:",pk"`
+	Label     *Label   `bun:"rel:belongs-to,join:label_id=id"`
+}
+
+func TestNestedHasMany(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.GetResource("Author").AddNestedRelation("Books", brest.OrphanDelete)
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Author", ContentType: brest.Json, Content: []byte(`{"Firstname":"Albert","Lastname":"Camus","Books":[{"Title":"L'Étranger"},{"Title":"La Peste"}]}`)})
+	assert.Nil(t, err)
+	author := res.(*Author)
+	assert.Equal(t, 2, len(author.Books))
+	assert.NotZero(t, author.Books[0].ID)
+	assert.Equal(t, author.ID, author.Books[1].AuthorID)
+
+	count, err := db.NewSelect().Model((*Book)(nil)).Where("author_id = ?", author.ID).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Put, Resource: "Author", Key: "1", ContentType: brest.Json, Content: []byte(`{"Firstname":"Albert","Lastname":"Camus","Books":[{"ID":2},{"Title":"La Chute"}]}`)})
+	assert.Nil(t, err)
+	books := make([]Book, 0)
+	err = db.NewSelect().Model(&books).Where("author_id = ?", author.ID).Order("id").Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(books))
+	assert.Equal(t, "La Peste", books[0].Title)
+	assert.Equal(t, "La Chute", books[1].Title)
+
+	// patch writes children, fields present in payload are updated even if zero
+	_, err = db.NewUpdate().Model((*Book)(nil)).Set("nb_pages = 300").Where("id = 2").Exec(context.Background())
+	assert.Nil(t, err)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Author", Key: "1", ContentType: brest.Json, Content: []byte(`{"Books":[{"ID":2,"NbPages":0},{"Title":"L'Exil et le Royaume"}]}`)})
+	assert.Nil(t, err)
+	books = make([]Book, 0)
+	err = db.NewSelect().Model(&books).Where("author_id = ?", author.ID).Order("id").Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(books))
+	assert.Equal(t, "La Peste", books[0].Title)
+	assert.Equal(t, 0, books[0].NbPages)
+	assert.Equal(t, "L'Exil et le Royaume", books[2].Title)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Author", Key: "1", ContentType: brest.MergePatchJson, Content: []byte(`{"Books":[{"ID":2,"Title":""}]}`)})
+	assert.Nil(t, err)
+	book := new(Book)
+	err = db.NewSelect().Model(book).Where("id = 2").Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "", book.Title)
+	assert.Equal(t, author.ID, book.AuthorID)
+}
+
+func TestNestedBelongsTo(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.GetResource("Book").AddNestedRelation("Author", brest.OrphanKeep)
+	engine := brest.NewEngine(config)
+
+	res, err := engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Book", ContentType: brest.Json, Content: []byte(`{"Title":"Le Procès","Author":{"Firstname":"Franz","Lastname":"Kafka"}}`)})
+	assert.Nil(t, err)
+	book := res.(*Book)
+	assert.NotZero(t, book.AuthorID)
+	assert.Equal(t, book.Author.ID, book.AuthorID)
+}
+
+func TestNestedM2M(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	db.RegisterModel((*ArticleLabel)(nil))
+	db.ResetModel(context.Background(), (*Article)(nil), (*Label)(nil), (*ArticleLabel)(nil))
+	article := brest.NewResource("Article", (*Article)(nil), brest.All)
+	article.AddNestedRelation("Labels", brest.OrphanUnlink)
+	config.AddResource(article)
+	config.AddResource(brest.NewResource("Label", (*Label)(nil), brest.All))
+	engine := brest.NewEngine(config)
+
+	var err error
+	var count int
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Article", ContentType: brest.Json, Content: []byte(`{"Title":"Go","Labels":[{"Name":"lang"},{"Name":"dev"}]}`)})
+	assert.Nil(t, err)
+	count, err = db.NewSelect().Model((*ArticleLabel)(nil)).Where("article_id = 1").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Put, Resource: "Article", Key: "1", ContentType: brest.Json, Content: []byte(`{"Title":"Go","Labels":[{"ID":2}]}`)})
+	assert.Nil(t, err)
+	count, err = db.NewSelect().Model((*ArticleLabel)(nil)).Where("article_id = 1").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	count, err = db.NewSelect().Model((*Label)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestNestedM2MOrphanDelete(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	db.RegisterModel((*ArticleLabel)(nil))
+	db.ResetModel(context.Background(), (*Article)(nil), (*Label)(nil), (*ArticleLabel)(nil))
+	article := brest.NewResource("Article", (*Article)(nil), brest.All)
+	article.AddNestedRelation("Labels", brest.OrphanDelete)
+	config.AddResource(article)
+	config.AddResource(brest.NewResource("Label", (*Label)(nil), brest.All))
+	engine := brest.NewEngine(config)
+
+	var err error
+	var count int
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Article", ContentType: brest.Json, Content: []byte(`{"Title":"Go","Labels":[{"Name":"lang"},{"Name":"dev"},{"Name":"web"}]}`)})
+	assert.Nil(t, err)
+	// label linked to another article isn't deleted
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Article", ContentType: brest.Json, Content: []byte(`{"Title":"Rust","Labels":[{"ID":1}]}`)})
+	assert.Nil(t, err)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Put, Resource: "Article", Key: "1", ContentType: brest.Json, Content: []byte(`{"Title":"Go","Labels":[{"ID":2}]}`)})
+	assert.Nil(t, err)
+	count, err = db.NewSelect().Model((*ArticleLabel)(nil)).Where("article_id = 1").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	labels := make([]Label, 0)
+	err = db.NewSelect().Model(&labels).Order("id").Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(labels))
+	assert.Equal(t, "lang", labels[0].Name)
+	assert.Equal(t, "dev", labels[1].Name)
+	count, err = db.NewSelect().Model((*ArticleLabel)(nil)).Where("article_id = 2").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestNestedChildResource(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.GetResource("Author").AddNestedRelation("Books", brest.OrphanKeep)
+	engine := brest.NewEngine(config)
+
+	var err error
+	created := 0
+	config.GetResource("Book").AddHook(brest.BeforeCreate, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		created++
+		return nil
+	})
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Author", ContentType: brest.Json, Content: []byte(`{"Firstname":"Albert","Lastname":"Camus","Books":[{"Title":"L'Étranger"}]}`)})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, created)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Author", ContentType: brest.Json, Content: []byte(`{"Firstname":"Franz","Lastname":"Kafka","Books":[{"Title":"Le Procès"}]}`)})
+	assert.Nil(t, err)
+
+	// book of another author can't be updated nor moved
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Author", Key: "1", ContentType: brest.Json, Content: []byte(`{"Books":[{"ID":2,"Title":"hacked"}]}`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+	book := new(Book)
+	err = db.NewSelect().Model(book).Where("id = 2").Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "Le Procès", book.Title)
+	assert.Equal(t, 2, book.AuthorID)
+
+	// book resource must allow nested writes
+	config.AddResource(brest.NewResource("Book", (*Book)(nil), brest.Get))
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Author", Key: "1", ContentType: brest.Json, Content: []byte(`{"Books":[{"ID":1,"Title":"hacked"}]}`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 403, err.(*brest.Error).Code)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Author", Key: "1", ContentType: brest.Json, Content: []byte(`{"Books":[{"Title":"La Peste"}]}`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 403, err.(*brest.Error).Code)
+	count, err := db.NewSelect().Model((*Book)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -44,6 +44,37 @@ const (
 	Null Op = "null"
 	// Nnull operation for attribute (? IS NOT NULL)
 	Nnull Op = "nnull"
+	// Between operation for attribute (? BETWEEN ? AND ?), value is an array of 2 values
+	Between Op = "between"
+	// Nbetween operation for attribute (? NOT BETWEEN ? AND ?), value is an array of 2 values
+	Nbetween Op = "nbetween"
+	// StartsWith operation for attribute (? LIKE 'value%'), wildcards of value are escaped
+	StartsWith Op = "startswith"
+	// EndsWith operation for attribute (? LIKE '%value'), wildcards of value are escaped
+	EndsWith Op = "endswith"
+	// Contains operation for attribute (? LIKE '%value%'), wildcards of value are escaped
+	Contains Op = "contains"
+	// Ieq operation for attribute (lower(?) = lower(?))
+	Ieq Op = "ieq"
+	// ArrayContains operation for Postgres array attribute (? @> ?)
+	ArrayContains Op = "acontains"
+	// ArrayOverlap operation for Postgres array attribute (? && ?)
+	ArrayOverlap Op = "aoverlap"
+	// JSONExists operation for JSON attribute, value is a JSON path ("a.b" or "$.a.b")
+	JSONExists Op = "jexists"
+	// JSONEq operation for JSON attribute, value is an array of JSON path and value
+	JSONEq Op = "jeq"
+	// DatePart operation for date attribute, value is an array of part (year, month, day, dow, hour, minute),
+	// comparison operation (eq, neq, gt, gte, lt, lte) and value
+	DatePart Op = "datepart"
+	// Intersects operation for geometry attribute, value is a bounding box [minx, miny, maxx, maxy] in attribute SRID
+	Intersects Op = "intersects"
+	// Within operation for geometry attribute, value is a GeoJSON geometry in attribute SRID
+	Within Op = "within"
+	// DWithin operation for geometry attribute, value is an array of GeoJSON geometry and distance in attribute SRID units
+	DWithin Op = "dwithin"
+	// Fts full-text search operation on resource search fields, attribute is ignored
+	Fts Op = "fts"
 )
 
 func (o Op) String() string {
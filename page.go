@@ -2,10 +2,11 @@ package brest
 
 // Page structure
 type Page struct {
-	Slice  interface{} `json:"slice"`
-	Offset int         `json:"offset"`
-	Limit  int         `json:"limit"`
-	Count  int         `json:"count"`
+	Slice  interface{}              `json:"slice"`
+	Offset int                      `json:"offset"`
+	Limit  int                      `json:"limit"`
+	Count  int                      `json:"count"`
+	Facets map[string][]*FacetValue `json:"facets,omitempty"`
 }
 
 // NewPage constructs Page
@@ -0,0 +1,415 @@
+package brest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun/schema"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var mergePatchContentType = regexp.MustCompile("[+-/]merge-patch\\+json($|[+-;])")
+
+var jsonPatchContentType = regexp.MustCompile("[+-/]json-patch\\+json($|[+-;])")
+
+// jsonPatchOperation structure (RFC 6902)
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+// isPatchContentType returns true for JSON Merge Patch and JSON Patch content types
+func isPatchContentType(contentType string) bool {
+	return mergePatchContentType.MatchString(contentType) || jsonPatchContentType.MatchString(contentType)
+}
+
+// deserializePatch applies patch content to entity and returns touched columns and patched document,
+// nil columns means all columns
+func (e *Engine) deserializePatch(restQuery *RestQuery, resource *Resource, entity interface{}) ([]string, interface{}, error) {
+	content, ok := restQuery.Content.([]byte)
+	if !ok || !isPatchContentType(restQuery.ContentType) {
+		return nil, nil, e.Deserialize(restQuery, resource, entity)
+	}
+	current, err := json.Marshal(entity)
+	if err != nil {
+		return nil, nil, NewErrorFromCause(err)
+	}
+	var doc interface{}
+	if err = decodeJSON(current, &doc); err != nil {
+		return nil, nil, NewErrorFromCause(err)
+	}
+	keys := make([]string, 0)
+	if mergePatchContentType.MatchString(restQuery.ContentType) {
+		var patch interface{}
+		if err = decodeJSON(content, &patch); err != nil {
+			return nil, nil, NewErrorBadRequest(fmt.Sprintf("invalid merge patch: %v", err))
+		}
+		object, ok := patch.(map[string]interface{})
+		if !ok {
+			return nil, nil, NewErrorBadRequest("invalid merge patch: JSON object expected")
+		}
+		for key := range object {
+			keys = append(keys, key)
+		}
+		doc = applyMergePatch(doc, patch)
+	} else {
+		operations := make([]*jsonPatchOperation, 0)
+		if err = decodeJSON(content, &operations); err != nil {
+			return nil, nil, NewErrorBadRequest(fmt.Sprintf("invalid json patch: %v", err))
+		}
+		for _, operation := range operations {
+			for _, path := range []string{operation.Path, operation.From} {
+				if tokens, err := splitPointer(path); err == nil && len(tokens) > 0 {
+					keys = append(keys, tokens[0])
+				}
+			}
+		}
+		if doc, err = applyJSONPatch(doc, operations); err != nil {
+			return nil, nil, err
+		}
+	}
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, NewErrorFromCause(err)
+	}
+	fresh := reflect.New(resource.ResourceType())
+	if err = json.Unmarshal(patched, fresh.Interface()); err != nil {
+		return nil, nil, NewErrorBadRequest(fmt.Sprintf("invalid patched entity: %v", err))
+	}
+	copyJSONFields(reflect.ValueOf(entity).Elem(), fresh.Elem())
+	return jsonKeysToColumns(e.config.db.Table(resource.ResourceType()), keys), doc, nil
+}
+
+// copyJSONFields copies fields serialized in JSON from src to dst, fields tagged `json:"-"` and unexported fields
+// of dst are kept as loaded
+func copyJSONFields(dst reflect.Value, src reflect.Value) {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" || sf.Tag.Get("json") == "-" {
+			continue
+		}
+		dst.Field(i).Set(src.Field(i))
+	}
+}
+
+// payloadColumns returns columns present in content, nil columns means all columns,
+// absent or empty content has no columns
+func (e *Engine) payloadColumns(restQuery *RestQuery, resource *Resource) ([]string, error) {
+	if restQuery.Content == nil {
+		return []string{}, nil
+	}
+	content, ok := restQuery.Content.([]byte)
+	if !ok {
+		return nil, nil
+	}
+	if len(content) == 0 {
+		return []string{}, nil
+	}
+	table := e.config.db.Table(resource.ResourceType())
+	keys := make([]string, 0)
+	if regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.ContentType) {
+		object := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(content, &object); err != nil {
+			return nil, NewErrorFromCause(err)
+		}
+		for key := range object {
+			keys = append(keys, key)
+		}
+		return jsonKeysToColumns(table, keys), nil
+	} else if regexp.MustCompile("[+-/]form($|[+-;])").MatchString(restQuery.ContentType) {
+		for _, keyValue := range strings.Split(string(content), "&") {
+			parts := strings.Split(keyValue, "=")
+			if len(parts) == 2 {
+				keys = append(keys, parts[0])
+			}
+		}
+		return formKeysToColumns(table, keys), nil
+	} else if regexp.MustCompile("[+-/](msgpack|messagepack)($|[+-])").MatchString(restQuery.ContentType) {
+		object := make(map[string]interface{})
+		if err := msgpack.Unmarshal(content, &object); err != nil {
+			return nil, NewErrorFromCause(err)
+		}
+		for key := range object {
+			keys = append(keys, key)
+		}
+		return jsonKeysToColumns(table, keys), nil
+	}
+	return nil, nil
+}
+
+// formKeysToColumns returns non pk column names of fields matching form keys by Go name or column name
+func formKeysToColumns(table *schema.Table, keys []string) []string {
+	columns := make([]string, 0)
+	for _, field := range table.Fields {
+		if field.IsPK {
+			continue
+		}
+		for _, key := range keys {
+			if key == field.GoName || key == field.Name {
+				columns = append(columns, field.Name)
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// jsonKeysToColumns returns non pk column names of fields serialized with JSON keys
+func jsonKeysToColumns(table *schema.Table, keys []string) []string {
+	columns := make([]string, 0)
+	for _, field := range table.Fields {
+		if field.IsPK {
+			continue
+		}
+		name := jsonName(field)
+		for _, key := range keys {
+			// encoding/json matches keys case-insensitively
+			if strings.EqualFold(key, name) {
+				columns = append(columns, field.Name)
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// jsonName returns JSON key of field
+func jsonName(field *schema.Field) string {
+	tag := strings.Split(field.StructField.Tag.Get("json"), ",")[0]
+	if tag != "" && tag != "-" {
+		return tag
+	}
+	return field.GoName
+}
+
+// decodeJSON decodes JSON keeping numbers as json.Number
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// applyMergePatch applies JSON Merge Patch (RFC 7396) to target
+func applyMergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = applyMergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// applyJSONPatch applies JSON Patch (RFC 6902) operations to doc
+func applyJSONPatch(doc interface{}, operations []*jsonPatchOperation) (interface{}, error) {
+	for _, operation := range operations {
+		path, err := splitPointer(operation.Path)
+		if err != nil {
+			return nil, err
+		}
+		switch operation.Op {
+		case "add":
+			doc, err = pointerAdd(doc, path, operation.Value)
+		case "remove":
+			doc, _, err = pointerRemove(doc, path)
+		case "replace":
+			if doc, _, err = pointerRemove(doc, path); err == nil {
+				doc, err = pointerAdd(doc, path, operation.Value)
+			}
+		case "move", "copy":
+			var from []string
+			var value interface{}
+			if from, err = splitPointer(operation.From); err != nil {
+				return nil, err
+			}
+			if operation.Op == "move" {
+				doc, value, err = pointerRemove(doc, from)
+			} else {
+				value, err = pointerGet(doc, from)
+				if err == nil {
+					value, err = deepCopyJSON(value)
+				}
+			}
+			if err == nil {
+				doc, err = pointerAdd(doc, path, value)
+			}
+		case "test":
+			var value interface{}
+			if value, err = pointerGet(doc, path); err == nil && !reflect.DeepEqual(value, operation.Value) {
+				return nil, NewErrorConflict(fmt.Sprintf("json patch test failed for path '%v'", operation.Path))
+			}
+		default:
+			return nil, NewErrorBadRequest(fmt.Sprintf("unknown json patch operation '%v'", operation.Op))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// splitPointer splits JSON pointer (RFC 6901) into unescaped tokens
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, NewErrorBadRequest(fmt.Sprintf("invalid json pointer '%v'", pointer))
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses array index token, max is the greatest allowed index
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max {
+		return 0, NewErrorBadRequest(fmt.Sprintf("invalid json pointer array index '%v'", token))
+	}
+	return index, nil
+}
+
+// pointerGet returns value at path
+func pointerGet(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[token]
+			if !ok {
+				return nil, NewErrorBadRequest(fmt.Sprintf("json pointer member '%v' not found", token))
+			}
+			node = child
+		case []interface{}:
+			index, err := arrayIndex(token, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[index]
+		default:
+			return nil, NewErrorBadRequest(fmt.Sprintf("json pointer token '%v' not found", token))
+		}
+	}
+	return node, nil
+}
+
+// pointerAdd adds value at path and returns updated node
+func pointerAdd(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token := path[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			n[token] = value
+			return n, nil
+		}
+		child, ok := n[token]
+		if !ok {
+			return nil, NewErrorBadRequest(fmt.Sprintf("json pointer member '%v' not found", token))
+		}
+		child, err := pointerAdd(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = child
+		return n, nil
+	case []interface{}:
+		if len(path) == 1 {
+			index := len(n)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(n)); err != nil {
+					return nil, err
+				}
+			}
+			n = append(n, nil)
+			copy(n[index+1:], n[index:])
+			n[index] = value
+			return n, nil
+		}
+		index, err := arrayIndex(token, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := pointerAdd(n[index], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[index] = child
+		return n, nil
+	}
+	return nil, NewErrorBadRequest(fmt.Sprintf("json pointer token '%v' not found", token))
+}
+
+// pointerRemove removes value at path and returns updated node and removed value
+func pointerRemove(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, node, nil
+	}
+	token := path[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[token]
+		if !ok {
+			return nil, nil, NewErrorBadRequest(fmt.Sprintf("json pointer member '%v' not found", token))
+		}
+		if len(path) == 1 {
+			delete(n, token)
+			return n, child, nil
+		}
+		child, removed, err := pointerRemove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[token] = child
+		return n, removed, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(n)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := n[index]
+			return append(n[:index], n[index+1:]...), removed, nil
+		}
+		child, removed, err := pointerRemove(n[index], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[index] = child
+		return n, removed, nil
+	}
+	return nil, nil, NewErrorBadRequest(fmt.Sprintf("json pointer token '%v' not found", token))
+}
+
+// deepCopyJSON copies decoded JSON value
+func deepCopyJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var copied interface{}
+	err = decodeJSON(data, &copied)
+	return copied, err
+}
@@ -0,0 +1,179 @@
+package brest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+func TestMergePatch(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+	var queries []string
+
+	config.AddUpdateQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.UpdateQuery) error {
+		queries = append(queries, query.String())
+		return nil
+	})
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Book", ContentType: brest.Json, Content: []byte("{\"Title\":\"title\",\"NbPages\":100,\"AuthorID\":1}")})
+	assert.Nil(t, err)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.MergePatchJson, Content: []byte("{\"NbPages\":null,\"AuthorID\":2}")})
+	assert.Nil(t, err)
+	resBook := res.(*Book)
+	assert.Equal(t, "title", resBook.Title)
+	assert.Equal(t, 0, resBook.NbPages)
+	assert.Equal(t, 2, resBook.AuthorID)
+	assert.Equal(t, 1, len(queries))
+	assert.True(t, strings.Contains(queries[0], "nb_pages"))
+	assert.False(t, strings.Contains(queries[0], "title"))
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.MergePatchJson, Content: []byte("{}")})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.(*Book).AuthorID)
+	assert.Equal(t, 1, len(queries))
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.MergePatchJson, Content: []byte("[1]")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).StatusCode())
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Book", ContentType: brest.MergePatchJson, Content: []byte("{\"Title\":\"title\"}")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).StatusCode())
+}
+
+type Account struct {
+	ID    int `bun:",pk,autoincrement"`
+	Login string
+	Token string `json:"-"`
+}
+
+func TestMergePatchNonJsonFields(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	config.AddResource(brest.NewResource("Account", (*Account)(nil), brest.All))
+	db.ResetModel(context.Background(), (*Account)(nil))
+	engine := brest.NewEngine(config)
+
+	_, err := db.NewInsert().Model(&Account{Login: "login", Token: "secret"}).Exec(context.Background())
+	assert.Nil(t, err)
+
+	res, err := engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Account", Key: "1", ContentType: brest.MergePatchJson, Content: []byte("{\"Login\":\"other\"}")})
+	assert.Nil(t, err)
+	assert.Equal(t, "other", res.(*Account).Login)
+	assert.Equal(t, "secret", res.(*Account).Token)
+}
+
+func TestJsonPatch(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Book", ContentType: brest.Json, Content: []byte("{\"Title\":\"title\",\"NbPages\":100,\"AuthorID\":1}")})
+	assert.Nil(t, err)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.JsonPatch, Content: []byte(`[
+		{"op":"test","path":"/Title","value":"title"},
+		{"op":"replace","path":"/Title","value":"new title"},
+		{"op":"copy","from":"/NbPages","path":"/AuthorID"},
+		{"op":"remove","path":"/NbPages"}
+	]`)})
+	assert.Nil(t, err)
+	resBook := res.(*Book)
+	assert.Equal(t, "new title", resBook.Title)
+	assert.Equal(t, 0, resBook.NbPages)
+	assert.Equal(t, 100, resBook.AuthorID)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.JsonPatch, Content: []byte(`[
+		{"op":"test","path":"/Title","value":"title"},
+		{"op":"replace","path":"/Title","value":"other title"}
+	]`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 409, err.(*brest.Error).StatusCode())
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.JsonPatch, Content: []byte(`[{"op":"move","from":"/Unknown","path":"/Title"}]`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).StatusCode())
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Key: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "new title", res.(*Book).Title)
+}
+
+func TestPartialPatch(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+	var queries []string
+
+	config.AddUpdateQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.UpdateQuery) error {
+		queries = append(queries, query.String())
+		return nil
+	})
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Book", ContentType: brest.Json, Content: []byte("{\"Title\":\"title\",\"NbPages\":100,\"AuthorID\":1}")})
+	assert.Nil(t, err)
+
+	// concurrent update of another column
+	_, err = db.NewUpdate().Model((*Book)(nil)).Set("title = ?", "concurrent title").Where("id = 1").Exec(context.Background())
+	assert.Nil(t, err)
+
+	// before patch hooks receive entity with key only
+	var hookBook Book
+	config.GetResource("Book").AddHook(brest.BeforePatch, func(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) error {
+		hookBook = *entity.(*Book)
+		return nil
+	})
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.Json, Content: []byte("{\"NbPages\":200}")})
+	assert.Nil(t, err)
+	assert.Equal(t, Book{ID: 1}, hookBook)
+	resBook := res.(*Book)
+	assert.Equal(t, "concurrent title", resBook.Title)
+	assert.Equal(t, 200, resBook.NbPages)
+	assert.Equal(t, 1, resBook.AuthorID)
+	assert.Equal(t, 1, len(queries))
+	assert.False(t, strings.Contains(queries[0], "title"))
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.Form, Content: []byte("author_id=2")})
+	assert.Nil(t, err)
+	resBook = res.(*Book)
+	assert.Equal(t, "concurrent title", resBook.Title)
+	assert.Equal(t, 200, resBook.NbPages)
+	assert.Equal(t, 2, resBook.AuthorID)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.Json, Content: []byte("{\"UnknownField\":1}")})
+	assert.Nil(t, err)
+	assert.Equal(t, 200, res.(*Book).NbPages)
+	assert.Equal(t, 2, len(queries))
+
+	// absent or empty content updates nothing
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.Json})
+	assert.Nil(t, err)
+	assert.Equal(t, Book{ID: 1, Title: "concurrent title", NbPages: 200, AuthorID: 2}, *res.(*Book))
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Book", Key: "1", ContentType: brest.Form, Content: []byte{}})
+	assert.Nil(t, err)
+	assert.Equal(t, Book{ID: 1, Title: "concurrent title", NbPages: 200, AuthorID: 2}, *res.(*Book))
+	assert.Equal(t, 2, len(queries))
+	book := &Book{ID: 1}
+	err = db.NewSelect().Model(book).WherePK().Scan(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "concurrent title", book.Title)
+	assert.Equal(t, 200, book.NbPages)
+	assert.Equal(t, 2, book.AuthorID)
+}
@@ -0,0 +1,63 @@
+package brest
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// SelectQueryHook defines callback function modifying select query before execution
+type SelectQueryHook func(ctx context.Context, restQuery *RestQuery, query *bun.SelectQuery) error
+
+// InsertQueryHook defines callback function modifying insert query before execution
+type InsertQueryHook func(ctx context.Context, restQuery *RestQuery, query *bun.InsertQuery) error
+
+// UpdateQueryHook defines callback function modifying update query before execution
+type UpdateQueryHook func(ctx context.Context, restQuery *RestQuery, query *bun.UpdateQuery) error
+
+// DeleteQueryHook defines callback function modifying delete query before execution
+type DeleteQueryHook func(ctx context.Context, restQuery *RestQuery, query *bun.DeleteQuery) error
+
+// queryHooks structure stores ordered query hooks
+type queryHooks struct {
+	selectHooks []SelectQueryHook
+	insertHooks []InsertQueryHook
+	updateHooks []UpdateQueryHook
+	deleteHooks []DeleteQueryHook
+}
+
+func (h *queryHooks) runSelect(ctx context.Context, restQuery *RestQuery, query *bun.SelectQuery) error {
+	for _, hook := range h.selectHooks {
+		if err := hook(ctx, restQuery, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *queryHooks) runInsert(ctx context.Context, restQuery *RestQuery, query *bun.InsertQuery) error {
+	for _, hook := range h.insertHooks {
+		if err := hook(ctx, restQuery, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *queryHooks) runUpdate(ctx context.Context, restQuery *RestQuery, query *bun.UpdateQuery) error {
+	for _, hook := range h.updateHooks {
+		if err := hook(ctx, restQuery, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *queryHooks) runDelete(ctx context.Context, restQuery *RestQuery, query *bun.DeleteQuery) error {
+	for _, hook := range h.deleteHooks {
+		if err := hook(ctx, restQuery, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
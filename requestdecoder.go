@@ -9,26 +9,48 @@ import (
 	"strings"
 )
 
+// actionFromMethod returns action of http method
+func actionFromMethod(method string) Action {
+	switch strings.ToUpper(method) {
+	case "GET":
+		return Get
+	case "POST":
+		return Post
+	case "PUT":
+		return Put
+	case "PATCH":
+		return Patch
+	case "DELETE":
+		return Delete
+	}
+	return None
+}
+
+// aggregateRegexp matches aggregate parameter item (e.g. "avg(NbPages)")
+var aggregateRegexp = regexp.MustCompile(`^(\w+)\(\s*(\*|[\w.]+)\s*\)$`)
+
 // RequestDecoder decodes rest parameters from request
 func RequestDecoder(request *http.Request, config *Config) *RestQuery {
-	re := regexp.MustCompile("(" + config.Prefix() + ")([^/\\?]+)/?([^/\\?]+)?/?([^/\\?]+)?")
+	re := regexp.MustCompile("(" + config.Prefix() + ")([^/\\?]+)/?([^/\\?]+)?/?([^/\\?]+)?/?([^/\\?]+)?/?([^/\\?]+)?")
 	res := re.FindStringSubmatch(request.RequestURI)
-	action := None
-	if request.Method == "GET" {
-		action = Get
-	} else if request.Method == "POST" {
-		action = Post
-	} else if request.Method == "PUT" {
-		action = Put
-	} else if request.Method == "PATCH" {
-		action = Patch
-	} else if request.Method == "DELETE" {
-		action = Delete
-	}
-	if res != nil && res[4] == "" && action != None {
+	tileRe := regexp.MustCompile("^(" + config.Prefix() + ")([^/\\?]+)/tiles/(\\d+)/(\\d+)/(\\d+)\\.mvt($|\\?)")
+	tileRes := tileRe.FindStringSubmatch(request.RequestURI)
+	action := actionFromMethod(request.Method)
+	if res != nil && (res[6] == "" || tileRes != nil) && action != None {
 		restQuery := &RestQuery{Request: request, Action: action, Offset: 0, Limit: 10}
 		restQuery.Resource = res[2]
-		restQuery.Key = res[3]
+		if tileRes != nil {
+			z, _ := strconv.Atoi(tileRes[3])
+			x, _ := strconv.Atoi(tileRes[4])
+			y, _ := strconv.Atoi(tileRes[5])
+			restQuery.Tile = &Tile{Z: z, X: x, Y: y}
+			// tiles aren't paginated unless limit is given
+			restQuery.Limit = 0
+		} else {
+			restQuery.Key = res[3]
+			restQuery.SubResource = res[4]
+			restQuery.SubKey = res[5]
+		}
 
 		params := request.URL.Query()
 
@@ -92,6 +114,64 @@ func RequestDecoder(request *http.Request, config *Config) *RestQuery {
 			json.Unmarshal([]byte(filterStr), restQuery.Filter)
 		}
 
+		restQuery.Search = strings.TrimSpace(params.Get("search"))
+
+		bboxStrs := strings.Split(strings.TrimSpace(params.Get("bbox")), ",")
+		if len(bboxStrs) == 4 {
+			bbox := make([]float64, 0, 4)
+			for _, s := range bboxStrs {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+					bbox = append(bbox, f)
+				}
+			}
+			if len(bbox) == 4 {
+				restQuery.Bbox = bbox
+			}
+		}
+
+		aggregatesStr := strings.TrimSpace(params.Get("aggregate"))
+		aggregatesStrs := strings.Split(aggregatesStr, ",")
+		restQuery.Aggregates = make([]*Aggregate, 0)
+		for _, s := range aggregatesStrs {
+			st := strings.TrimSpace(s)
+			if st != "" {
+				if res := aggregateRegexp.FindStringSubmatch(st); res != nil {
+					restQuery.Aggregates = append(restQuery.Aggregates, &Aggregate{AggregateFunc(strings.ToLower(res[1])), strings.TrimSpace(res[2])})
+				} else {
+					restQuery.Aggregates = append(restQuery.Aggregates, &Aggregate{AggregateFunc(st), ""})
+				}
+			}
+		}
+
+		groupByStr := strings.TrimSpace(params.Get("groupBy"))
+		groupByStrs := strings.Split(groupByStr, ",")
+		restQuery.GroupBy = make([]*Field, 0)
+		for _, s := range groupByStrs {
+			st := strings.TrimSpace(s)
+			if st != "" {
+				restQuery.GroupBy = append(restQuery.GroupBy, &Field{st})
+			}
+		}
+
+		facetsStr := strings.TrimSpace(params.Get("facets"))
+		facetsStrs := strings.Split(facetsStr, ",")
+		restQuery.Facets = make([]*Field, 0)
+		for _, s := range facetsStrs {
+			st := strings.TrimSpace(s)
+			if st != "" {
+				restQuery.Facets = append(restQuery.Facets, &Field{st})
+			}
+		}
+
+		restQuery.FacetLimit = 10
+		if facetLimit, err := strconv.ParseInt(params.Get("facetLimit"), 10, 64); err == nil {
+			restQuery.FacetLimit = int(facetLimit)
+		}
+
+		if bestEffort, err := strconv.ParseBool(params.Get("bestEffort")); err == nil {
+			restQuery.BestEffort = bestEffort
+		}
+
 		if debug, err := strconv.ParseBool(params.Get("debug")); err == nil {
 			restQuery.Debug = debug
 		}
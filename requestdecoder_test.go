@@ -37,7 +37,12 @@ var requestDecoderTests = []struct {
 	{"/rest/User/1", "PUT", &brest.RestQuery{Action: brest.Put, Resource: "User", Key: "1", ContentType: brest.Json, Content: make([]byte, 0)}},
 	{"/rest/User/1", "PATCH", &brest.RestQuery{Action: brest.Patch, Resource: "User", Key: "1", ContentType: brest.Json, Content: make([]byte, 0)}},
 	{"/rest/User/1", "DELETE", &brest.RestQuery{Action: brest.Delete, Resource: "User", Key: "1"}},
-	{"/rest/User/specific/otherservice", "GET", nil},
+	{"/rest/Place?bbox=2.2,48.8,2.5,48.9", "GET", &brest.RestQuery{Action: brest.Get, Resource: "Place", Offset: 0, Limit: 10, Fields: []*brest.Field{}, Sorts: []*brest.Sort{}, Filter: &brest.Filter{}, Bbox: []float64{2.2, 48.8, 2.5, 48.9}}},
+	{"/rest/Place?bbox=2.2,48.8,2.5", "GET", &brest.RestQuery{Action: brest.Get, Resource: "Place", Offset: 0, Limit: 10, Fields: []*brest.Field{}, Sorts: []*brest.Sort{}, Filter: &brest.Filter{}}},
+	{"/rest/Place/tiles/3/4/5.mvt?fields=name", "GET", &brest.RestQuery{Action: brest.Get, Resource: "Place", Offset: 0, Limit: 0, Fields: []*brest.Field{{Name: "name"}}, Sorts: []*brest.Sort{}, Filter: &brest.Filter{}, Tile: &brest.Tile{Z: 3, X: 4, Y: 5}}},
+	{"/rest/User/1/Roles", "GET", &brest.RestQuery{Action: brest.Get, Resource: "User", Key: "1", SubResource: "Roles", Offset: 0, Limit: 10, Fields: []*brest.Field{}, Sorts: []*brest.Sort{}, Filter: &brest.Filter{}}},
+	{"/rest/User/1/Roles/2", "PUT", &brest.RestQuery{Action: brest.Put, Resource: "User", Key: "1", SubResource: "Roles", SubKey: "2", ContentType: brest.Json, Content: make([]byte, 0)}},
+	{"/rest/User/specific/otherservice/1/other", "GET", nil},
 	{"/rest", "GET", nil},
 	{"/", "GET", nil},
 }
@@ -12,6 +12,8 @@ type RestQuery struct {
 	Action      Action
 	Resource    string
 	Key         string
+	SubResource string
+	SubKey      string
 	ContentType string
 	Accept      string
 	Content     interface{}
@@ -21,7 +23,16 @@ type RestQuery struct {
 	Relations   []*Relation
 	Sorts       []*Sort
 	Filter      *Filter
+	Search      string
+	Bbox        []float64
+	Tile        *Tile
+	Aggregates  []*Aggregate
+	GroupBy     []*Field
+	Facets      []*Field
+	FacetLimit  int
+	BestEffort  bool
 	Debug       bool
+	scope       *scope
 }
 
 func (q *RestQuery) String() string {
@@ -37,6 +48,18 @@ func (q *RestQuery) String() string {
 	} else {
 		str = fmt.Sprintf("<action=%v resource=%v key=%v content-type=%v>", q.Action, q.Resource, q.Key, q.ContentType)
 	}
+	if len(q.Aggregates) > 0 || len(q.GroupBy) > 0 {
+		str = fmt.Sprintf("%v aggregates=%v groupBy=%v>", strings.TrimSuffix(str, ">"), q.Aggregates, q.GroupBy)
+	}
+	if q.Tile != nil {
+		str = fmt.Sprintf("%v tile=%v>", strings.TrimSuffix(str, ">"), q.Tile)
+	}
+	if len(q.Bbox) > 0 {
+		str = fmt.Sprintf("%v bbox=%v>", strings.TrimSuffix(str, ">"), q.Bbox)
+	}
+	if q.SubResource != "" {
+		str = fmt.Sprintf("%v sub-resource=%v sub-key=%v>", strings.TrimSuffix(str, ">"), q.SubResource, q.SubKey)
+	}
 	return str
 }
 
@@ -77,6 +100,7 @@ type Filter struct {
 	Attr    string      // attribute name
 	Value   interface{} // attribute value
 	Filters []*Filter   // sub filters for 'and' and 'or' operations
+	expr    string      // SQL expression of computed attribute
 }
 
 func (f *Filter) String() string {
@@ -0,0 +1,225 @@
+package brest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// RelevanceSort sort name ordering by full-text search relevance of rest query search
+const RelevanceSort = "$relevance"
+
+// search structure stores full-text search configuration of resource
+type search struct {
+	fields   []string
+	index    string
+	language string
+}
+
+// SetSearchFields sets fields (Go or column names) used by full-text search
+func (r *Resource) SetSearchFields(fields ...string) {
+	r.search.fields = fields
+}
+
+// SetSearchIndex sets FTS5 virtual table used by full-text search on SQLite, "<table>_fts" by default
+//
+// The FTS5 table indexes search fields with resource pk as rowid, see CreateSearchIndex.
+func (r *Resource) SetSearchIndex(index string) {
+	r.search.index = index
+}
+
+// SetSearchLanguage sets text search configuration used by full-text search on Postgres, "simple" by default
+func (r *Resource) SetSearchLanguage(language string) {
+	r.search.language = language
+}
+
+// searchIndex returns FTS5 virtual table name
+func (r *Resource) searchIndex(table *schema.Table) string {
+	if r.search.index != "" {
+		return r.search.index
+	}
+	return table.Name + "_fts"
+}
+
+// searchFields returns search fields of table
+func searchFields(table *schema.Table, resource *Resource) ([]*schema.Field, error) {
+	if resource == nil || len(resource.search.fields) == 0 {
+		return nil, NewErrorBadRequest(fmt.Sprintf("full-text search not configured for resource '%v'", table.TypeName))
+	}
+	fields := make([]*schema.Field, len(resource.search.fields))
+	for i, name := range resource.search.fields {
+		if fields[i] = tableField(table, name); fields[i] == nil {
+			return nil, NewErrorBadRequest(fmt.Sprintf("unknown search field '%v' for resource '%v'", name, resource.Name()))
+		}
+	}
+	return fields, nil
+}
+
+// ftsQuery quotes each word of text for FTS5, operators of text are not interpreted
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = "\"" + strings.ReplaceAll(word, "\"", "\"\"") + "\""
+	}
+	return strings.Join(words, " ")
+}
+
+// tsVector returns Postgres tsvector expression of search fields, qualified by table alias in queries
+func tsVector(fields []*schema.Field, language string, qualified bool) (string, []interface{}) {
+	columns := make([]string, len(fields))
+	args := []interface{}{language}
+	for i, field := range fields {
+		if qualified {
+			columns[i] = "coalesce(?TableAlias.?, '')"
+		} else {
+			columns[i] = "coalesce(?, '')"
+		}
+		args = append(args, bun.Ident(field.Name))
+	}
+	return "to_tsvector(?::regconfig, " + strings.Join(columns, " || ' ' || ") + ")", args
+}
+
+// searchLanguage returns Postgres text search configuration of resource
+func (r *Resource) searchLanguage() string {
+	if r.search.language != "" {
+		return r.search.language
+	}
+	return "simple"
+}
+
+// CreateSearchIndex creates full-text search index of resource search fields
+//
+// On SQLite, an external content FTS5 virtual table kept in sync by triggers is created and rebuilt.
+// On Postgres, a GIN index on tsvector is created. On MySQL, a FULLTEXT index is created.
+func CreateSearchIndex(ctx context.Context, db *bun.DB, resource *Resource) error {
+	table := db.Table(resource.ResourceType())
+	fields, err := searchFields(table, resource)
+	if err != nil {
+		return err
+	}
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = string(field.SQLName)
+	}
+	queries := make([]string, 0)
+	args := make([][]interface{}, 0)
+	switch db.Dialect().Name() {
+	case dialect.SQLite:
+		if len(table.PKs) != 1 {
+			return NewErrorBadRequest(fmt.Sprintf("full-text search needs single pk for resource '%v'", resource.Name()))
+		}
+		index := resource.searchIndex(table)
+		list := strings.Join(columns, ", ")
+		values := func(prefix string) string {
+			items := []string{prefix + string(table.PKs[0].SQLName)}
+			for _, column := range columns {
+				items = append(items, prefix+column)
+			}
+			return strings.Join(items, ", ")
+		}
+		insert := "INSERT INTO ?0 (rowid, " + list + ") VALUES (" + values("new.") + ");"
+		remove := "INSERT INTO ?0 (?0, rowid, " + list + ") VALUES ('delete', " + values("old.") + ");"
+		queries = append(queries,
+			"CREATE VIRTUAL TABLE IF NOT EXISTS ?0 USING fts5("+list+", content=?1, content_rowid=?2)",
+			"CREATE TRIGGER IF NOT EXISTS ?3 AFTER INSERT ON ?4 BEGIN "+insert+" END",
+			"CREATE TRIGGER IF NOT EXISTS ?5 AFTER DELETE ON ?4 BEGIN "+remove+" END",
+			"CREATE TRIGGER IF NOT EXISTS ?6 AFTER UPDATE ON ?4 BEGIN "+remove+" "+insert+" END",
+			"INSERT INTO ?0 (?0) VALUES ('rebuild')")
+		params := []interface{}{bun.Ident(index), table.Name, table.PKs[0].Name, bun.Ident(index + "_ai"), bun.Ident(table.Name), bun.Ident(index + "_ad"), bun.Ident(index + "_au")}
+		for range queries {
+			args = append(args, params)
+		}
+	case dialect.PG:
+		vector, vectorArgs := tsVector(fields, resource.searchLanguage(), false)
+		queries = append(queries, "CREATE INDEX IF NOT EXISTS ? ON ? USING GIN (("+vector+"))")
+		args = append(args, append([]interface{}{bun.Ident(table.Name + "_fts"), bun.Ident(table.Name)}, vectorArgs...))
+	case dialect.MySQL:
+		queries = append(queries, "CREATE FULLTEXT INDEX ? ON ? ("+strings.Join(columns, ", ")+")")
+		args = append(args, []interface{}{bun.Ident(table.Name + "_fts"), bun.Ident(table.Name)})
+	default:
+		return NewErrorBadRequest(fmt.Sprintf("full-text search not supported for dialect '%v'", db.Dialect().Name()))
+	}
+	for i, query := range queries {
+		if _, err = db.ExecContext(ctx, query, args[i]...); err != nil {
+			return NewErrorFromCause(err)
+		}
+	}
+	return nil
+}
+
+// searchExpr returns condition and relevance expressions with their arguments for dialect
+func searchExpr(query *bun.SelectQuery, resource *Resource, text string) (string, string, []interface{}, error) {
+	table := query.DB().Table(resource.ResourceType())
+	fields, err := searchFields(table, resource)
+	if err != nil {
+		return "", "", nil, err
+	}
+	switch query.Dialect().Name() {
+	case dialect.SQLite:
+		if len(table.PKs) != 1 {
+			return "", "", nil, NewErrorBadRequest(fmt.Sprintf("full-text search needs single pk for resource '%v'", resource.Name()))
+		}
+		index := bun.Ident(resource.searchIndex(table))
+		args := []interface{}{bun.Ident(table.PKs[0].Name), index, index, ftsQuery(text)}
+		return "?TableAlias.? IN (SELECT rowid FROM ? WHERE ? MATCH ?)",
+			"(SELECT -rank FROM ? WHERE ? MATCH ? AND rowid = ?TableAlias.?)",
+			args, nil
+	case dialect.PG:
+		language := resource.searchLanguage()
+		vector, args := tsVector(fields, language, true)
+		args = append(args, language, text)
+		return vector + " @@ websearch_to_tsquery(?::regconfig, ?)", "ts_rank(" + vector + ", websearch_to_tsquery(?::regconfig, ?))", args, nil
+	case dialect.MySQL:
+		columns := make([]string, len(fields))
+		args := make([]interface{}, 0)
+		for i, field := range fields {
+			columns[i] = "?TableAlias.?"
+			args = append(args, bun.Ident(field.Name))
+		}
+		args = append(args, text)
+		expr := "MATCH (" + strings.Join(columns, ", ") + ") AGAINST (? IN NATURAL LANGUAGE MODE)"
+		return expr, expr, args, nil
+	}
+	return "", "", nil, NewErrorBadRequest(fmt.Sprintf("full-text search not supported for dialect '%v'", query.Dialect().Name()))
+}
+
+// searchCondition returns full-text search condition with its arguments
+func searchCondition(query *bun.SelectQuery, resource *Resource, text string) (string, []interface{}, error) {
+	condition, _, args, err := searchExpr(query, resource, text)
+	return condition, args, err
+}
+
+// searchRank returns order expression by relevance with its arguments, most relevant last if asc
+func searchRank(query *bun.SelectQuery, resource *Resource, text string, asc bool) (string, []interface{}, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", nil, NewErrorBadRequest("relevance sort needs search")
+	}
+	_, rank, args, err := searchExpr(query, resource, text)
+	if err != nil {
+		return "", nil, err
+	}
+	if query.Dialect().Name() == dialect.SQLite {
+		// rank compares to rowid in last position
+		args = []interface{}{args[1], args[2], args[3], args[0]}
+	}
+	if asc {
+		return rank + " ASC", args, nil
+	}
+	return rank + " DESC", args, nil
+}
+
+// addQuerySearch adds full-text search condition
+func addQuerySearch(query *bun.SelectQuery, resource *Resource, text string) (*bun.SelectQuery, error) {
+	if strings.TrimSpace(text) == "" {
+		return query, nil
+	}
+	condition, args, err := searchCondition(query, resource, text)
+	if err != nil {
+		return nil, err
+	}
+	return query.Where(condition, args...), nil
+}
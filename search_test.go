@@ -0,0 +1,68 @@
+package brest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	resource := config.GetResource("Book")
+	resource.SetSearchFields("Title")
+	db.ExecContext(context.Background(), "DROP TABLE IF EXISTS books_fts")
+	err := brest.CreateSearchIndex(context.Background(), db, resource)
+	assert.Nil(t, err)
+	engine := brest.NewEngine(config)
+
+	var res interface{}
+
+	for _, book := range books {
+		_, err = db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+	_, err = db.NewUpdate().Model((*Book)(nil)).Set("title = ?", "Le Petit Prince de la nuit").Where("id = 2").Exec(context.Background())
+	assert.Nil(t, err)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 10, Search: "nuit"})
+	assert.Nil(t, err)
+	page := res.(*brest.Page)
+	assert.Equal(t, 1, page.Count)
+	assert.Equal(t, "Le Petit Prince de la nuit", (*page.Slice.(*[]Book))[0].Title)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 10, Search: "petit prince", Sorts: []*brest.Sort{{Name: brest.RelevanceSort, Asc: false}}})
+	assert.Nil(t, err)
+	page = res.(*brest.Page)
+	assert.Equal(t, 2, page.Count)
+	assert.Equal(t, "Le Petit Prince", (*page.Slice.(*[]Book))[0].Title)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 10, Filter: &brest.Filter{Op: brest.Or, Filters: []*brest.Filter{{Op: brest.Fts, Value: "château"}, {Op: brest.Eq, Attr: "author_id", Value: 3}}}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.(*brest.Page).Count)
+
+	// blank full-text filter doesn't restrict list
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 10, Filter: &brest.Filter{Op: brest.And, Filters: []*brest.Filter{{Op: brest.Fts, Value: " "}, {Op: brest.Eq, Attr: "author_id", Value: 3}}}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.(*brest.Page).Count)
+
+	// bulk delete is restricted by search as list
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Book", Search: "nuit", Filter: &brest.Filter{Op: brest.Gt, Attr: "id", Value: 0}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.(*brest.BulkResult).Succeeded)
+	count, err := db.NewSelect().Model((*Book)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, len(books)-1, count)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Book", Key: "10"})
+	assert.Nil(t, err)
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Limit: 10, Search: "\"château"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, res.(*brest.Page).Count)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Author", Limit: 10, Search: "kafka"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.(*brest.Error).Code)
+}
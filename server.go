@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"regexp"
 
 	"github.com/vmihailenco/msgpack/v5"
@@ -33,7 +34,15 @@ func (s *Server) SetNextHandler(next http.Handler) {
 func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	restQuery := RequestDecoder(request, s.Config())
 	if restQuery != nil {
-		res, err := s.Execute(restQuery)
+		var res interface{}
+		var status int
+		var err error
+		if regexp.MustCompile("[+-/]geo\\+json($|[+-;])").MatchString(restQuery.Accept) {
+			err = checkGeoJSONWrite(s.Config(), restQuery)
+		}
+		if err == nil {
+			res, status, err = s.execute(restQuery)
+		}
 		if err != nil {
 			s.Config().ErrorLogger().Printf("%v\n", err.Error())
 			if cerr, ok := err.(*Error); ok {
@@ -46,22 +55,19 @@ func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 			serialized, contentType, err := s.Serialize(restQuery, res)
 			if err != nil {
 				s.Config().ErrorLogger().Printf("%v\n", err.Error())
-				http.Error(writer, err.Error(), http.StatusInternalServerError)
-			} else {
-				if restQuery.Action == Get {
-					writer.WriteHeader(http.StatusOK)
-				} else if restQuery.Action == Post {
-					writer.WriteHeader(http.StatusCreated)
-				} else if restQuery.Action == Put {
-					writer.WriteHeader(http.StatusOK)
-				} else if restQuery.Action == Patch {
-					writer.WriteHeader(http.StatusOK)
-				} else if restQuery.Action == Delete {
-					writer.WriteHeader(http.StatusNoContent)
+				if cerr, ok := err.(*Error); ok {
+					http.Error(writer, cerr.Error(), cerr.StatusCode())
 				} else {
-					writer.WriteHeader(http.StatusOK)
+					http.Error(writer, err.Error(), http.StatusInternalServerError)
+				}
+			} else {
+				if status == http.StatusCreated {
+					if location := s.location(restQuery, res); location != "" {
+						writer.Header().Set("Location", location)
+					}
 				}
 				writer.Header().Add("Content-Type", contentType)
+				writer.WriteHeader(status)
 				writer.Write(serialized)
 			}
 		}
@@ -80,15 +86,24 @@ func (s *Server) Serialize(restQuery *RestQuery, entity interface{}) ([]byte, st
 	var contentType string
 	var data []byte
 	var err error
-	if regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.Accept) {
-		data, err = json.Marshal(entity)
+	if tile, ok := entity.(VectorTile); ok {
+		return tile, Mvt, nil
+	}
+	if regexp.MustCompile("[+-/]geo\\+json($|[+-;])").MatchString(restQuery.Accept) {
+		var geo interface{}
+		if geo, err = geoJSON(s.Config(), restQuery, entity); err == nil {
+			data, err = json.Marshal(geo)
+		}
+		contentType = "application/geo+json; charset=utf-8"
+	} else if regexp.MustCompile("[+-/]json($|[+-;])").MatchString(restQuery.Accept) {
+		data, err = json.Marshal(projectFields(s.Config(), restQuery, entity))
 		contentType = "application/json; charset=utf-8"
 	} else if regexp.MustCompile("[+-/]msgpack($|[+-;])").MatchString(restQuery.Accept) {
 		var buf bytes.Buffer
 		encoder := msgpack.NewEncoder(&buf)
 		encoder.SetCustomStructTag("json")
 		encoder.UseCompactInts(true)
-		err = encoder.Encode(entity)
+		err = encoder.Encode(projectFields(s.Config(), restQuery, entity))
 		data = buf.Bytes()
 		contentType = "application/x-msgpack"
 	} else {
@@ -97,3 +112,23 @@ func (s *Server) Serialize(restQuery *RestQuery, entity interface{}) ([]byte, st
 	}
 	return data, contentType, err
 }
+
+// location returns url path of created entity, empty if unknown
+func (s *Server) location(restQuery *RestQuery, entity interface{}) string {
+	entityType := reflect.TypeOf(entity)
+	if entityType.Kind() != reflect.Ptr || entityType.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+	resource := s.Config().GetResource(restQuery.Resource)
+	if resource == nil || resource.ResourceType() != entityType.Elem() {
+		resource = s.Config().getResourceByType(entityType.Elem())
+	}
+	if resource == nil || resource.Virtual() != nil {
+		return ""
+	}
+	key, err := resource.pkKey(s.Config().DB(), reflect.ValueOf(entity).Elem())
+	if err != nil {
+		return ""
+	}
+	return s.Config().Prefix() + resource.Name() + "/" + key
+}
@@ -2,6 +2,7 @@ package brest_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/aptogeo/brest"
 	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
 )
 
 func TestServer(t *testing.T) {
@@ -105,3 +107,76 @@ func TestServer(t *testing.T) {
 	err = res.Body.Close()
 	assert.Nil(t, err)
 }
+
+func TestServerPut(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	server := brest.NewServer(config)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var err error
+	var req *http.Request
+	var body []byte
+	var res *http.Response
+	var resAuthor *Author
+
+	// upsert runs insert query hooks whether entity is created or replaced
+	inserts, updates := 0, 0
+	config.GetResource("Author").AddInsertQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.InsertQuery) error {
+		inserts++
+		return nil
+	})
+	config.GetResource("Author").AddUpdateQueryHook(func(ctx context.Context, restQuery *brest.RestQuery, query *bun.UpdateQuery) error {
+		updates++
+		return nil
+	})
+
+	req, err = http.NewRequest("PUT", ts.URL+"/rest/Author/7", bytes.NewBufferString("{\"Firstname\":\"created\"}"))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	body, err = ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+	resAuthor = &Author{}
+	err = json.Unmarshal(body, resAuthor)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, resAuthor.ID)
+	assert.Equal(t, "created", resAuthor.Firstname)
+
+	req, err = http.NewRequest("PUT", ts.URL+"/rest/Author/7", bytes.NewBufferString("{\"Firstname\":\"replaced\"}"))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	count, err := db.NewSelect().Model(&Author{}).Where("firstname = ?", "replaced").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.True(t, inserts >= 2)
+	assert.Equal(t, 0, updates)
+
+	config.GetResource("Author").SetPutMode(brest.PutUpdate)
+
+	req, err = http.NewRequest("PUT", ts.URL+"/rest/Author/8", bytes.NewBufferString("{\"Firstname\":\"missing\"}"))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+
+	req, err = http.NewRequest("PUT", ts.URL+"/rest/Author/7", bytes.NewBufferString("{\"Firstname\":\"updated\"}"))
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	err = res.Body.Close()
+	assert.Nil(t, err)
+}
@@ -0,0 +1,88 @@
+// Package sqlitefunc registers SQL functions used by brest filter operations with modernc.org/sqlite driver
+package sqlitefunc
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"modernc.org/sqlite"
+)
+
+// unaccentReplacer replaces accented latin letters by their base letter
+var unaccentReplacer = func() *strings.Replacer {
+	pairs := []string{
+		"àáâãäåā", "a", "ÀÁÂÃÄÅĀ", "A", "çćč", "c", "ÇĆČ", "C", "ď", "d", "Ď", "D",
+		"èéêëēėęě", "e", "ÈÉÊËĒĖĘĚ", "E", "ìíîïī", "i", "ÌÍÎÏĪ", "I", "ñńň", "n", "ÑŃŇ", "N",
+		"òóôõöøō", "o", "ÒÓÔÕÖØŌ", "O", "řŕ", "r", "ŘŔ", "R", "śšş", "s", "ŚŠŞ", "S", "ťţ", "t", "ŤŢ", "T",
+		"ùúûüūů", "u", "ÙÚÛÜŪŮ", "U", "ýÿ", "y", "ÝŸ", "Y", "źżž", "z", "ŹŻŽ", "Z",
+		"æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE", "ß", "ss",
+	}
+	oldnew := make([]string, 0)
+	for i := 0; i < len(pairs); i += 2 {
+		for _, r := range pairs[i] {
+			oldnew = append(oldnew, string(r), pairs[i+1])
+		}
+	}
+	return strings.NewReplacer(oldnew...)
+}()
+
+// Unaccent removes accents of latin letters, implementation of unaccent SQL function
+func Unaccent(s string) string {
+	return unaccentReplacer.Replace(s)
+}
+
+// regexps caches compiled regular expressions of regexp SQL function
+var regexps sync.Map
+
+// RegexpMatch reports whether s matches pattern, implementation of regexp SQL function
+func RegexpMatch(pattern string, s string) (bool, error) {
+	re, ok := regexps.Load(pattern)
+	if !ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		re, _ = regexps.LoadOrStore(pattern, compiled)
+	}
+	return re.(*regexp.Regexp).MatchString(s), nil
+}
+
+var registerOnce sync.Once
+var registerErr error
+
+// Register registers unaccent and regexp SQL functions, used by Lulk, Nlulk, Sim and Nsim
+// operations, with modernc.org/sqlite driver registered as "sqlite" for connections opened afterwards,
+// these operations are then enabled by Config.SetSQLiteFunctions for databases opened with this driver
+//
+// With another SQLite driver (or driver instance), Unaccent and RegexpMatch must be registered with the driver.
+func Register() error {
+	registerOnce.Do(func() {
+		registerErr = sqlite.RegisterDeterministicScalarFunction("unaccent", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			if args[0] == nil {
+				return nil, nil
+			}
+			return Unaccent(text(args[0])), nil
+		})
+		if registerErr != nil {
+			return
+		}
+		registerErr = sqlite.RegisterDeterministicScalarFunction("regexp", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			if args[0] == nil || args[1] == nil {
+				return nil, nil
+			}
+			return RegexpMatch(text(args[0]), text(args[1]))
+		})
+	})
+	return registerErr
+}
+
+// text returns text of SQLite value
+func text(value driver.Value) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,265 @@
+package brest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// scope restricts a sub-resource query to the children of a parent
+type scope struct {
+	where  func(q *bun.SelectQuery) *bun.SelectQuery
+	assign func(elem reflect.Value)
+}
+
+// applyWhere restricts select query to scope
+func (s *scope) applyWhere(q *bun.SelectQuery) *bun.SelectQuery {
+	if s == nil || s.where == nil {
+		return q
+	}
+	return s.where(q)
+}
+
+// applyAssign sets scope foreign keys to entity
+func (s *scope) applyAssign(elem reflect.Value) {
+	if s == nil || s.assign == nil {
+		return
+	}
+	s.assign(elem)
+}
+
+// executeSubResource executes a rest query on relation of parent resource
+//
+// For has-one and has-many relations, children are listed, created, and read, replaced, patched or
+// deleted by sub key. For m2m relations, children are listed, created and linked, read by sub key,
+// Put links and Delete unlinks existing child. For belongs-to relation, parent is read.
+func (e *Engine) executeSubResource(ctx context.Context, restQuery *RestQuery, resource *Resource) (interface{}, int, error) {
+	if resource.Action()&Get == 0 {
+		return nil, 0, NewErrorForbbiden(fmt.Sprintf("query %v not authorized for resource %v", restQuery, resource))
+	}
+	if restQuery.Key == "" {
+		return nil, 0, NewErrorBadRequest("sub-resource: key is mandatory")
+	}
+	if resource.Virtual() != nil {
+		return nil, 0, NewErrorBadRequest(fmt.Sprintf("sub-resource: resource '%v' is virtual", resource.Name()))
+	}
+	db := e.config.DB()
+	table := db.Table(resource.ResourceType())
+	rel, ok := table.Relations[restQuery.SubResource]
+	if !ok {
+		return nil, 0, NewErrorNotFound(fmt.Sprintf("relation '%v' not found for resource '%v'", restQuery.SubResource, resource.Name()))
+	}
+	child := e.config.getResourceByType(rel.JoinTable.Type)
+	if child == nil {
+		return nil, 0, NewErrorNotFound(fmt.Sprintf("no resource for relation '%v' of resource '%v'", restQuery.SubResource, resource.Name()))
+	}
+
+	var res interface{}
+	var status int
+	err := Execute(ctx, func(ctx context.Context, tx *bun.Tx) error {
+		parent := reflect.New(resource.ResourceType()).Elem()
+		if err := e.setKey(ctx, resource, parent, restQuery.Key); err != nil {
+			return err
+		}
+		err := tx.NewSelect().Model(parent.Addr().Interface()).WherePK().Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return NewErrorNotFound(fmt.Sprintf("resource '%v' with key '%v' not found", resource.Name(), restQuery.Key))
+		}
+		if err != nil {
+			return err
+		}
+		childQuery := *restQuery
+		childQuery.Resource = child.Name()
+		childQuery.Key = restQuery.SubKey
+		childQuery.SubResource = ""
+		childQuery.SubKey = ""
+		switch relationKind(rel) {
+		case "belongs-to":
+			return e.executeBelongsTo(ctx, &childQuery, child, rel, parent, &res, &status)
+		case "has-one", "has-many":
+			childQuery.scope = hasManyScope(rel, parent)
+			if childQuery.Key != "" && childQuery.Action != Get {
+				if err = e.checkInScope(ctx, tx, child, &childQuery); err != nil {
+					return err
+				}
+			}
+			res, status, err = e.executeContext(ctx, &childQuery)
+			return err
+		case "m2m":
+			return e.executeM2M(ctx, tx, &childQuery, resource, rel, parent, &res, &status)
+		}
+		return NewErrorBadRequest(fmt.Sprintf("sub-resource: unsupported relation '%v'", restQuery.SubResource))
+	})
+	if err != nil {
+		return nil, 0, NewErrorFromCause(err)
+	}
+	return res, status, nil
+}
+
+// executeBelongsTo gets parent of a belongs-to relation
+func (e *Engine) executeBelongsTo(ctx context.Context, childQuery *RestQuery, child *Resource, rel *schema.Relation, parent reflect.Value, res *interface{}, status *int) error {
+	if childQuery.Action != Get || childQuery.Key != "" {
+		return NewErrorBadRequest(fmt.Sprintf("sub-resource: only 'Get' without key is supported for relation '%v'", rel.Field.GoName))
+	}
+	elem := reflect.New(rel.JoinTable.Type).Elem()
+	for i, join := range rel.JoinFields {
+		join.Value(elem).Set(rel.BaseFields[i].Value(parent))
+	}
+	key, err := child.pkKey(e.config.DB(), elem)
+	if err != nil {
+		return NewErrorNotFound(fmt.Sprintf("relation '%v' not set", rel.Field.GoName))
+	}
+	childQuery.Key = key
+	*res, *status, err = e.executeContext(ctx, childQuery)
+	return err
+}
+
+// executeM2M lists, creates, gets, links or unlinks children of a m2m relation
+func (e *Engine) executeM2M(ctx context.Context, tx *bun.Tx, childQuery *RestQuery, resource *Resource, rel *schema.Relation, parent reflect.Value, res *interface{}, status *int) error {
+	if len(rel.JoinFields) != 1 {
+		return NewErrorBadRequest(fmt.Sprintf("sub-resource: m2m relation '%v' needs single pk", rel.Field.GoName))
+	}
+	childQuery.scope = m2mScope(tx, rel, parent)
+	if childQuery.Action == Get {
+		var err error
+		*res, *status, err = e.executeContext(ctx, childQuery)
+		return err
+	}
+	if childQuery.Action == Post && childQuery.Key == "" {
+		var err error
+		if *res, *status, err = e.executeContext(ctx, childQuery); err != nil {
+			return err
+		}
+		result, ok := (*res).(*BulkResult)
+		if !ok {
+			return linkM2M(ctx, tx, rel, parent, reflect.Indirect(reflect.ValueOf(*res)))
+		}
+		// each created item of bulk creation is linked
+		for _, item := range result.Items {
+			if item.Entity == nil {
+				continue
+			}
+			if err = linkM2M(ctx, tx, rel, parent, reflect.Indirect(reflect.ValueOf(item.Entity))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if childQuery.Key == "" || (childQuery.Action != Put && childQuery.Action != Delete) {
+		return NewErrorBadRequest(fmt.Sprintf("sub-resource: only 'Put' and 'Delete' with key are supported for relation '%v'", rel.Field.GoName))
+	}
+	if resource.Action()&childQuery.Action == 0 {
+		return NewErrorForbbiden(fmt.Sprintf("query %v not authorized for resource %v", childQuery, resource))
+	}
+	elem := reflect.New(rel.JoinTable.Type).Elem()
+	if err := e.setKey(ctx, e.config.GetResource(childQuery.Resource), elem, childQuery.Key); err != nil {
+		return err
+	}
+	notFound := NewErrorNotFound(fmt.Sprintf("resource '%v' with key '%v' not found", childQuery.Resource, childQuery.Key))
+	if childQuery.Action == Put {
+		exists, err := tx.NewSelect().Model(elem.Addr().Interface()).WherePK().Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return notFound
+		}
+		*status = statusCode(Put, false)
+		return linkM2M(ctx, tx, rel, parent, elem)
+	}
+	*status = statusCode(Delete, false)
+	r, err := tx.NewDelete().Model(reflect.New(rel.M2MTable.Type).Interface()).ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+		return m2mLinkWhere(q, rel, parent, elem)
+	}).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := r.RowsAffected(); err == nil && n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// hasManyScope restricts children to those referencing parent
+func hasManyScope(rel *schema.Relation, parent reflect.Value) *scope {
+	return &scope{
+		where: func(q *bun.SelectQuery) *bun.SelectQuery {
+			for i, join := range rel.JoinFields {
+				q = q.Where("?TableAlias.? = ?", bun.Ident(join.Name), rel.BaseFields[i].Value(parent).Interface())
+			}
+			return q
+		},
+		assign: func(elem reflect.Value) {
+			for i, join := range rel.JoinFields {
+				join.Value(elem).Set(rel.BaseFields[i].Value(parent))
+			}
+		},
+	}
+}
+
+// m2mScope restricts children to those linked to parent
+func m2mScope(tx *bun.Tx, rel *schema.Relation, parent reflect.Value) *scope {
+	return &scope{
+		where: func(q *bun.SelectQuery) *bun.SelectQuery {
+			sq := tx.NewSelect().Model(reflect.New(rel.M2MTable.Type).Interface())
+			for _, m2mJoin := range rel.M2MJoinFields {
+				sq = sq.Column(m2mJoin.Name)
+			}
+			for i, m2mBase := range rel.M2MBaseFields {
+				sq = sq.Where("? = ?", bun.Ident(m2mBase.Name), rel.BaseFields[i].Value(parent).Interface())
+			}
+			return q.Where("?TableAlias.? IN (?)", bun.Ident(rel.JoinFields[0].Name), sq)
+		},
+	}
+}
+
+// m2mLinkWhere restricts query to link between parent and child
+func m2mLinkWhere(q bun.QueryBuilder, rel *schema.Relation, parent reflect.Value, child reflect.Value) bun.QueryBuilder {
+	for i, m2mBase := range rel.M2MBaseFields {
+		q = q.Where("? = ?", bun.Ident(m2mBase.Name), rel.BaseFields[i].Value(parent).Interface())
+	}
+	for i, m2mJoin := range rel.M2MJoinFields {
+		q = q.Where("? = ?", bun.Ident(m2mJoin.Name), rel.JoinFields[i].Value(child).Interface())
+	}
+	return q
+}
+
+// linkM2M inserts link between parent and child if missing
+func linkM2M(ctx context.Context, tx *bun.Tx, rel *schema.Relation, parent reflect.Value, child reflect.Value) error {
+	link := reflect.New(rel.M2MTable.Type)
+	exists, err := tx.NewSelect().Model(link.Interface()).ApplyQueryBuilder(func(q bun.QueryBuilder) bun.QueryBuilder {
+		return m2mLinkWhere(q, rel, parent, child)
+	}).Exists(ctx)
+	if err != nil || exists {
+		return err
+	}
+	for i, m2mBase := range rel.M2MBaseFields {
+		m2mBase.Value(link.Elem()).Set(rel.BaseFields[i].Value(parent))
+	}
+	for i, m2mJoin := range rel.M2MJoinFields {
+		m2mJoin.Value(link.Elem()).Set(rel.JoinFields[i].Value(child))
+	}
+	_, err = tx.NewInsert().Model(link.Interface()).Exec(ctx)
+	return err
+}
+
+// checkInScope returns not found error if child designated by rest query key is out of scope
+func (e *Engine) checkInScope(ctx context.Context, tx *bun.Tx, child *Resource, restQuery *RestQuery) error {
+	elem := reflect.New(child.ResourceType()).Elem()
+	if err := e.setKey(ctx, child, elem, restQuery.Key); err != nil {
+		return err
+	}
+	exists, err := restQuery.scope.applyWhere(tx.NewSelect().Model(elem.Addr().Interface()).WherePK()).Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return NewErrorNotFound(fmt.Sprintf("resource '%v' with key '%v' not found", restQuery.Resource, restQuery.Key))
+	}
+	return nil
+}
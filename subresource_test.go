@@ -0,0 +1,165 @@
+package brest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubResource(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	for _, author := range authors {
+		_, err = db.NewInsert().Model(&author).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+	for _, book := range books {
+		_, err = db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Author", Key: "2", SubResource: "Books", Limit: 2, Sorts: []*brest.Sort{{Name: "title", Asc: true}}})
+	assert.Nil(t, err)
+	page := res.(*brest.Page)
+	assert.Equal(t, 5, page.Count)
+	slice := *page.Slice.(*[]Book)
+	assert.Equal(t, 2, len(slice))
+	assert.Equal(t, "L'Amérique", slice[0].Title)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Author", Key: "2", SubResource: "Books", Limit: 10, Filter: &brest.Filter{Op: brest.Lk, Attr: "title", Value: "Le %"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.(*brest.Page).Count)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Author", Key: "3", SubResource: "Books", ContentType: brest.Json, Content: []byte(`{"Title":"Tendre est la nuit","AuthorID":1}`)})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, res.(*Book).AuthorID)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Author", Key: "3", SubResource: "Books", SubKey: "1"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Author", Key: "3", SubResource: "Books", SubKey: "1"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Book", Key: "7", SubResource: "Author"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Kafka", res.(*Author).Lastname)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Author", Key: "99", SubResource: "Books"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Author", Key: "1", SubResource: "Unknown"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+}
+
+func TestSubResourceM2M(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	db.RegisterModel((*ArticleLabel)(nil))
+	db.ResetModel(context.Background(), (*Article)(nil), (*Label)(nil), (*ArticleLabel)(nil))
+	config.AddResource(brest.NewResource("Article", (*Article)(nil), brest.All))
+	config.AddResource(brest.NewResource("Label", (*Label)(nil), brest.All))
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Article", ContentType: brest.Json, Content: []byte(`{"Title":"Go"}`)})
+	assert.Nil(t, err)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Label", ContentType: brest.Json, Content: []byte(`{"Name":"lang"}`)})
+	assert.Nil(t, err)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Put, Resource: "Article", Key: "1", SubResource: "Labels", SubKey: "1"})
+	assert.Nil(t, err)
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Article", Key: "1", SubResource: "Labels", ContentType: brest.Json, Content: []byte(`{"Name":"dev"}`)})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.(*Label).ID)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Article", Key: "1", SubResource: "Labels", Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.(*brest.Page).Count)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Article", Key: "1", SubResource: "Labels", SubKey: "1"})
+	assert.Nil(t, err)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Article", Key: "1", SubResource: "Labels", SubKey: "1"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Article", Key: "1", SubResource: "Labels", Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.(*brest.Page).Count)
+	count, err := db.NewSelect().Model((*Label)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestSubResourceBulk(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+	var count int
+
+	for _, author := range authors {
+		_, err = db.NewInsert().Model(&author).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+	for _, book := range books {
+		_, err = db.NewInsert().Model(&book).Exec(context.Background())
+		assert.Nil(t, err)
+	}
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Author", Key: "3", SubResource: "Books", ContentType: brest.Json, Content: []byte(`[{"Title":"Tendre est la nuit"},{"Title":"Gatsby le Magnifique","AuthorID":1}]`)})
+	assert.Nil(t, err)
+	result := res.(*brest.BulkResult)
+	assert.Equal(t, 2, result.Succeeded)
+	assert.Equal(t, 3, result.Items[0].Entity.(*Book).AuthorID)
+	assert.Equal(t, 3, result.Items[1].Entity.(*Book).AuthorID)
+
+	// book 1 belongs to author 1
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Author", Key: "3", SubResource: "Books", ContentType: brest.Json, Content: []byte(`[{"ID":1,"NbPages":10}]`)})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).StatusCode())
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Author", Key: "1", SubResource: "Books", Filter: &brest.Filter{Op: brest.Lk, Attr: "title", Value: "%"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, res.(*brest.BulkResult).Succeeded)
+	count, err = db.NewSelect().Model((*Book)(nil)).Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, len(books)-6+2, count)
+	count, err = db.NewSelect().Model((*Book)(nil)).Where("author_id = 1").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestSubResourceM2MBulk(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	db.RegisterModel((*ArticleLabel)(nil))
+	db.ResetModel(context.Background(), (*Article)(nil), (*Label)(nil), (*ArticleLabel)(nil))
+	config.AddResource(brest.NewResource("Article", (*Article)(nil), brest.All))
+	config.AddResource(brest.NewResource("Label", (*Label)(nil), brest.All))
+	engine := brest.NewEngine(config)
+
+	_, err := engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Article", ContentType: brest.Json, Content: []byte(`{"Title":"Go"}`)})
+	assert.Nil(t, err)
+
+	res, err := engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Article", Key: "1", SubResource: "Labels", ContentType: brest.Json, Content: []byte(`[{"Name":"lang"},{"Name":"dev"}]`)})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.(*brest.BulkResult).Succeeded)
+	count, err := db.NewSelect().Model((*ArticleLabel)(nil)).Where("article_id = 1").Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+}
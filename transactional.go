@@ -43,6 +43,11 @@ func (e propagationError) Error() string {
 	return e.Cause.Error()
 }
 
+// Unwrap returns cause
+func (e propagationError) Unwrap() error {
+	return e.Cause
+}
+
 // Execute executes ExecFunc in transaction
 func Execute(ctx context.Context, execFunc ExecFunc) error {
 	return execute(ctx, Current, execFunc)
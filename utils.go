@@ -2,20 +2,115 @@ package brest
 
 import (
 	"fmt"
+	"net/url"
 	"reflect"
+	"strings"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/schema"
 )
 
+// setPk sets pk of elem from path escaped key, values of composite pk are separated by commas ("12,5")
+// or given as matrix parameters named by column, Go or relation-style name (";book_id=12;tag_id=5",
+// ";BookID=12;TagID=5" or ";book=12;tag=5")
 func setPk(db *bun.DB, resourceType reflect.Type, elem reflect.Value, key string) error {
 	table := db.Table(resourceType)
-	if len(table.PKs) == 1 {
-		pk := table.PKs[0]
-		err := pk.ScanValue(elem, key)
+	if len(table.PKs) == 0 {
+		return NewErrorBadRequest(fmt.Sprintf("no pk for resource '%v'", resourceType))
+	}
+	if len(table.PKs) == 1 && !strings.HasPrefix(key, ";") {
+		unescaped, err := url.PathUnescape(key)
+		if err != nil {
+			return NewErrorBadRequest(fmt.Sprintf("invalid key '%v' for resource '%v'", key, resourceType))
+		}
+		return table.PKs[0].ScanValue(elem, unescaped)
+	}
+	values, err := splitKey(table, key)
+	if err != nil {
 		return err
 	}
-	return NewErrorBadRequest(fmt.Sprintf("only single pk is permitted for resource '%v'", resourceType))
+	for i, pk := range table.PKs {
+		if err = pk.ScanValue(elem, values[i]); err != nil {
+			return NewErrorBadRequest(fmt.Sprintf("invalid value '%v' of pk '%v' for resource '%v'", values[i], pk.Name, resourceType))
+		}
+	}
+	return nil
+}
+
+// splitKey returns pk values of key ordered as table pks
+func splitKey(table *schema.Table, key string) ([]string, error) {
+	values := make([]string, len(table.PKs))
+	if strings.HasPrefix(key, ";") {
+		found := 0
+		for _, param := range strings.Split(key[1:], ";") {
+			parts := strings.SplitN(param, "=", 2)
+			index := -1
+			for i, pk := range table.PKs {
+				if isPkParam(pk, parts[0]) {
+					index = i
+				}
+			}
+			if len(parts) != 2 || index == -1 {
+				return nil, NewErrorBadRequest(fmt.Sprintf("invalid key parameter '%v' for resource '%v'", param, table.TypeName))
+			}
+			values[index] = parts[1]
+			found++
+		}
+		if found != len(table.PKs) {
+			return nil, NewErrorBadRequest(fmt.Sprintf("key '%v' must contain %v pk values for resource '%v'", key, len(table.PKs), table.TypeName))
+		}
+	} else {
+		parts := strings.Split(key, ",")
+		if len(parts) != len(table.PKs) {
+			return nil, NewErrorBadRequest(fmt.Sprintf("key '%v' must contain %v pk values for resource '%v'", key, len(table.PKs), table.TypeName))
+		}
+		copy(values, parts)
+	}
+	for i, value := range values {
+		unescaped, err := url.PathUnescape(value)
+		if err != nil {
+			return nil, NewErrorBadRequest(fmt.Sprintf("invalid key '%v' for resource '%v'", key, table.TypeName))
+		}
+		values[i] = unescaped
+	}
+	return values, nil
+}
+
+// isPkParam returns true if matrix parameter name designates pk by column name, Go name,
+// or relation-style name without id suffix ("book" for "book_id" or "BookID")
+func isPkParam(pk *schema.Field, name string) bool {
+	if strings.EqualFold(pk.Name, name) || strings.EqualFold(pk.GoName, name) {
+		return true
+	}
+	column := strings.ToLower(pk.Name)
+	return strings.HasSuffix(column, "_id") && len(column) > 3 && strings.EqualFold(column[:len(column)-3], name)
+}
+
+// tableField returns field of table by Go or column name
+func tableField(table *schema.Table, name string) *schema.Field {
+	for _, field := range table.Fields {
+		if strings.EqualFold(field.GoName, name) || strings.EqualFold(field.Name, name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// getPk returns key of elem, values are path escaped, values of composite pk are separated by commas
+func getPk(db *bun.DB, resourceType reflect.Type, elem reflect.Value) (string, error) {
+	table := db.Table(resourceType)
+	if len(table.PKs) == 0 {
+		return "", NewErrorBadRequest(fmt.Sprintf("no pk for resource '%v'", resourceType))
+	}
+	values := make([]string, len(table.PKs))
+	for i, field := range table.PKs {
+		pk := field.Value(elem)
+		if pk.IsZero() {
+			return "", NewErrorBadRequest(fmt.Sprintf("pk is mandatory for resource '%v'", resourceType))
+		}
+		values[i] = strings.ReplaceAll(url.PathEscape(fmt.Sprint(pk.Interface())), ",", "%2C")
+	}
+	return strings.Join(values, ","), nil
 }
 
 func addQueryLimit(query *bun.SelectQuery, limit int) *bun.SelectQuery {
@@ -58,13 +153,29 @@ func addQueryRelations(query *bun.SelectQuery, relations []*Relation) *bun.Selec
 	return q
 }
 
-func addQuerySorts(query *bun.SelectQuery, sorts []*Sort) *bun.SelectQuery {
+func addQuerySorts(query *bun.SelectQuery, resource *Resource, search string, sorts []*Sort) (*bun.SelectQuery, error) {
 	if sorts == nil {
-		return query
+		return query, nil
 	}
 	q := query
 	if len(sorts) > 0 {
 		for _, sort := range sorts {
+			if sort.Name == RelevanceSort {
+				expr, args, err := searchRank(q, resource, search, sort.Asc)
+				if err != nil {
+					return nil, err
+				}
+				q = q.OrderExpr(expr, args...)
+				continue
+			}
+			if computed := resource.computedField(sort.Name); computed != nil && computed.Expr != "" {
+				if sort.Asc {
+					q = q.OrderExpr("(" + computed.Expr + ") ASC")
+				} else {
+					q = q.OrderExpr("(" + computed.Expr + ") DESC")
+				}
+				continue
+			}
 			var orderExpr string
 			if sort.Asc {
 				orderExpr = sort.Name + " ASC"
@@ -74,72 +185,54 @@ func addQuerySorts(query *bun.SelectQuery, sorts []*Sort) *bun.SelectQuery {
 			q = q.Order(orderExpr)
 		}
 	}
-	return q
+	return q, nil
 }
 
-func addQueryFilter(query *bun.SelectQuery, filter *Filter, parentGroupOp Op) *bun.SelectQuery {
-	if filter == nil {
-		return query
+func addQueryFilter(query *bun.SelectQuery, config *Config, resource *Resource, filter *Filter, parentGroupOp Op) (*bun.SelectQuery, error) {
+	if filter == nil || filter.Op == "" {
+		return query, nil
 	}
 
 	if filter.Op == And || filter.Op == Or {
-		return addWhereGroup(query,
+		var err error
+		q := addWhereGroup(query,
 			func(query *bun.SelectQuery) *bun.SelectQuery {
 				q := query
 				for _, subfilter := range filter.Filters {
-					q = addQueryFilter(query, subfilter, filter.Op)
+					if err == nil {
+						q, err = addQueryFilter(query, config, resource, subfilter, filter.Op)
+					}
 				}
 				return q
 			})
+		return q, err
 	}
 
-	switch filter.Op {
-	case Eq:
-		return addWhere(query, "? = ?", filter.Attr, filter.Value, parentGroupOp)
-	case Neq:
-		return addWhere(query, "? != ?", filter.Attr, filter.Value, parentGroupOp)
-	case In:
-		return addWhere(query, "? IN (?)", filter.Attr, bun.In(filter.Value), parentGroupOp)
-	case Nin:
-		return addWhere(query, "? NOT IN (?)", filter.Attr, bun.In(filter.Value), parentGroupOp)
-	case Gt:
-		return addWhere(query, "? > ?", filter.Attr, filter.Value, parentGroupOp)
-	case Gte:
-		return addWhere(query, "? >= ?", filter.Attr, filter.Value, parentGroupOp)
-	case Lt:
-		return addWhere(query, "? < ?", filter.Attr, filter.Value, parentGroupOp)
-	case Lte:
-		return addWhere(query, "? <= ?", filter.Attr, filter.Value, parentGroupOp)
-	case Lk:
-		return addWhere(query, "? LIKE ?", filter.Attr, filter.Value, parentGroupOp)
-	case Nlk:
-		return addWhere(query, "? NOT LIKE ?", filter.Attr, filter.Value, parentGroupOp)
-	case Llk:
-		return addWhere(query, "lower(?) LIKE lower(?)", filter.Attr, filter.Value, parentGroupOp)
-	case Nllk:
-		return addWhere(query, "lower(?) NOT LIKE lower(?)", filter.Attr, filter.Value, parentGroupOp)
-	case Sim:
-		return addWhere(query, "? SIMILAR TO ?", filter.Attr, filter.Value, parentGroupOp)
-	case Nsim:
-		return addWhere(query, "? NOT SIMILAR TO ?", filter.Attr, filter.Value, parentGroupOp)
-	case Lulk:
-		return addWhere(query, "lower(unaccent(?)) LIKE lower(unaccent(?))", filter.Attr, filter.Value, parentGroupOp)
-	case Nlulk:
-		return addWhere(query, "lower(unaccent(?)) NOT LIKE lower(unaccent(?))", filter.Attr, filter.Value, parentGroupOp)
-	case Null:
-		return addWhere(query, "? IS NULL", filter.Attr, "", parentGroupOp)
-	case Nnull:
-		return addWhere(query, "? IS NOT NULL", filter.Attr, "", parentGroupOp)
-	default:
-		return query
+	if filter.Op == Fts {
+		if filter.Value == nil || strings.TrimSpace(fmt.Sprint(filter.Value)) == "" {
+			// blank search doesn't restrict query, as search parameter
+			return query, nil
+		}
+		condition, args, err := searchCondition(query, resource, fmt.Sprint(filter.Value))
+		if err != nil {
+			return nil, err
+		}
+		return addWhereCondition(query, condition, args, parentGroupOp), nil
 	}
-}
 
-func addWhere(query *bun.SelectQuery, condition string, attribute string, value interface{}, parentGroupOp Op) *bun.SelectQuery {
-	if parentGroupOp == Or {
-		return query.WhereOr(condition, schema.Ident(attribute), value)
+	if computed := resource.computedField(filter.Attr); computed != nil {
+		if computed.Expr == "" {
+			return nil, NewErrorBadRequest(fmt.Sprintf("computed field '%v' can't be filtered", filter.Attr))
+		}
+		computedFilter := *filter
+		computedFilter.expr = computed.Expr
+		filter = &computedFilter
+	}
+	condition, args, err := renderCondition(query.Dialect().Name(), filter, config.SQLiteFunctions())
+	if err != nil {
+		return nil, err
 	}
-	return query.Where(condition, schema.Ident(attribute), value)
+	return addWhereCondition(query, condition, args, parentGroupOp), nil
 }
 
 func addWhereGroup(query *bun.SelectQuery, fnGroup func(query *bun.SelectQuery) *bun.SelectQuery) *bun.SelectQuery {
@@ -0,0 +1,129 @@
+package brest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// VirtualResource interface implemented by resources not backed by a bun model
+type VirtualResource interface {
+	// Get returns entity identified by rest query key
+	Get(ctx context.Context, restQuery *RestQuery) (interface{}, error)
+	// List returns slice and total count of entities matching rest query
+	List(ctx context.Context, restQuery *RestQuery) (interface{}, int, error)
+	// Create creates deserialized entity and returns created entity
+	Create(ctx context.Context, restQuery *RestQuery, entity interface{}) (interface{}, error)
+	// Update updates deserialized entity identified by rest query key and returns updated entity
+	Update(ctx context.Context, restQuery *RestQuery, entity interface{}) (interface{}, error)
+	// Delete deletes entity identified by rest query key
+	Delete(ctx context.Context, restQuery *RestQuery) error
+}
+
+// executeVirtual executes a rest query on virtual resource
+func (e *Engine) executeVirtual(ctx context.Context, restQuery *RestQuery, resource *Resource) (interface{}, error) {
+	var err error
+	entity := reflect.New(resource.ResourceType()).Interface()
+	switch restQuery.Action {
+	case Get:
+	case Post:
+		if restQuery.Key != "" {
+			return nil, NewErrorBadRequest("action 'Post': key is forbidden")
+		}
+		if err = e.Deserialize(restQuery, resource, entity); err != nil {
+			return nil, NewErrorFromCause(err)
+		}
+	case Put, Patch, Delete:
+		if restQuery.Key == "" {
+			return nil, NewErrorBadRequest(fmt.Sprintf("action '%v': key is mandatory", restQuery.Action))
+		}
+		if restQuery.Action == Put {
+			if err = e.Deserialize(restQuery, resource, entity); err != nil {
+				return nil, NewErrorFromCause(err)
+			}
+		}
+	default:
+		return nil, NewErrorBadRequest(fmt.Sprintf("unknow action '%v'", restQuery.Action))
+	}
+
+	beforeEvent, afterEvent := hookEvents(restQuery)
+	if err = e.runBeforeHooks(ctx, beforeEvent, restQuery, resource, entity); err != nil {
+		if sc, ok := shortCircuitFromError(err); ok {
+			return sc.Result, nil
+		}
+		return nil, NewErrorFromCause(err)
+	}
+
+	if restQuery.Debug {
+		e.Config().InfoLogger().Printf("Execution request: %v\n", restQuery)
+		e.Config().InfoLogger().Printf("Data: %v\n", entity)
+	}
+
+	virtual := resource.Virtual()
+	var result interface{}
+	var count int
+	switch restQuery.Action {
+	case Get:
+		if restQuery.Key != "" {
+			result, err = virtual.Get(ctx, restQuery)
+		} else {
+			result, count, err = virtual.List(ctx, restQuery)
+		}
+	case Post:
+		result, err = virtual.Create(ctx, restQuery, entity)
+	case Put:
+		result, err = virtual.Update(ctx, restQuery, entity)
+	case Patch:
+		result, err = virtual.Get(ctx, restQuery)
+		if err == nil && result != nil {
+			err = e.Deserialize(restQuery, resource, result)
+			if err == nil {
+				result, err = virtual.Update(ctx, restQuery, result)
+			}
+		}
+	case Delete:
+		err = virtual.Delete(ctx, restQuery)
+		result = entity
+	}
+	if err != nil {
+		return nil, NewErrorFromCause(err)
+	}
+	if result == nil {
+		if (restQuery.Action == Get && restQuery.Key != "") || restQuery.Action == Patch {
+			return nil, NewErrorNotFound(fmt.Sprintf("resource '%v' with key '%v' not found", resource.Name(), restQuery.Key))
+		}
+		return nil, nil
+	}
+
+	if restQuery.Debug {
+		e.Config().InfoLogger().Printf("Execution result: %v\n", result)
+	}
+
+	if restQuery.Action == Get && restQuery.Key == "" {
+		v := reflect.Indirect(reflect.ValueOf(result))
+		if v.Kind() == reflect.Slice {
+			for i := 0; i < v.Len(); i++ {
+				item := v.Index(i)
+				if item.Kind() != reflect.Ptr && item.Kind() != reflect.Interface {
+					item = item.Addr()
+				}
+				if err = e.runAfterHooks(ctx, afterEvent, restQuery, resource, item.Interface()); err != nil {
+					break
+				}
+			}
+		}
+	} else {
+		err = e.runAfterHooks(ctx, afterEvent, restQuery, resource, result)
+	}
+	if err != nil {
+		if sc, ok := shortCircuitFromError(err); ok {
+			return sc.Result, nil
+		}
+		return nil, NewErrorFromCause(err)
+	}
+
+	if restQuery.Action == Get && restQuery.Key == "" {
+		return NewPage(result, count, restQuery), nil
+	}
+	return result, nil
+}
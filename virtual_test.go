@@ -0,0 +1,92 @@
+package brest_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/aptogeo/brest"
+	"github.com/stretchr/testify/assert"
+)
+
+type Stat struct {
+	Name  string
+	Value int
+}
+
+type statStore struct {
+	stats map[string]*Stat
+}
+
+func (s *statStore) Get(ctx context.Context, restQuery *brest.RestQuery) (interface{}, error) {
+	if stat, ok := s.stats[restQuery.Key]; ok {
+		return stat, nil
+	}
+	return nil, nil
+}
+
+func (s *statStore) List(ctx context.Context, restQuery *brest.RestQuery) (interface{}, int, error) {
+	slice := make([]Stat, 0)
+	for _, stat := range s.stats {
+		slice = append(slice, *stat)
+	}
+	return &slice, len(slice), nil
+}
+
+func (s *statStore) Create(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) (interface{}, error) {
+	stat := entity.(*Stat)
+	s.stats[stat.Name] = stat
+	return stat, nil
+}
+
+func (s *statStore) Update(ctx context.Context, restQuery *brest.RestQuery, entity interface{}) (interface{}, error) {
+	stat := entity.(*Stat)
+	stat.Name = restQuery.Key
+	s.stats[stat.Name] = stat
+	return stat, nil
+}
+
+func (s *statStore) Delete(ctx context.Context, restQuery *brest.RestQuery) error {
+	delete(s.stats, restQuery.Key)
+	return nil
+}
+
+func TestVirtualResource(t *testing.T) {
+	db, config := initTests(t)
+	defer db.Close()
+	store := &statStore{stats: make(map[string]*Stat)}
+	config.AddResource(brest.NewVirtualResource("Stat", (*Stat)(nil), store, brest.All))
+	engine := brest.NewEngine(config)
+
+	var err error
+	var res interface{}
+
+	for i := 1; i <= 3; i++ {
+		res, err = engine.Execute(&brest.RestQuery{Action: brest.Post, Resource: "Stat", ContentType: brest.Json, Content: []byte("{\"Name\":\"stat" + strconv.Itoa(i) + "\",\"Value\":" + strconv.Itoa(i) + "}")})
+		assert.Nil(t, err)
+		assert.Equal(t, i, res.(*Stat).Value)
+	}
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Stat"})
+	assert.Nil(t, err)
+	page := res.(*brest.Page)
+	assert.Equal(t, 3, page.Count)
+	assert.Equal(t, 3, len(*page.Slice.(*[]Stat)))
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Stat", Key: "stat2", ContentType: brest.Form, Content: []byte("Value=20")})
+	assert.Nil(t, err)
+	assert.Equal(t, 20, res.(*Stat).Value)
+
+	res, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Stat", Key: "stat2"})
+	assert.Nil(t, err)
+	assert.Equal(t, 20, res.(*Stat).Value)
+
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Delete, Resource: "Stat", Key: "stat2"})
+	assert.Nil(t, err)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Get, Resource: "Stat", Key: "stat2"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+	_, err = engine.Execute(&brest.RestQuery{Action: brest.Patch, Resource: "Stat", Key: "stat2", ContentType: brest.Form, Content: []byte("Value=30")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 404, err.(*brest.Error).Code)
+}